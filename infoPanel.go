@@ -0,0 +1,86 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package main
+
+import (
+	"image"
+	"strings"
+
+	"gioui.org/layout"
+	"github.com/ajstarks/giocanvas"
+)
+
+const kInfoLineChars = 60 // of the event text, before wrapping
+
+// Returns the service information from the SDT and EIT
+func (ui *UI) q100_InfoPanel(gtx C, width, height int) D {
+	canvas := giocanvas.Canvas{
+		Width:   float32(width),
+		Height:  float32(height),
+		Context: gtx,
+		Theme:   ui.th,
+	}
+	canvas.Background(q100color.gfxBgd)
+
+	info := lmData.ServiceInfo
+	y := float32(90)
+	line := func(name, value string) {
+		if value == "" {
+			value = "-"
+		}
+		canvas.Text(5, y, kPanelTextSize, name, q100color.labelWhite)
+		canvas.Text(25, y, kPanelTextSize, value, q100color.labelOrange)
+		y -= kPanelRowStep
+	}
+	line("Service", info.Name)
+	line("Provider", info.Provider)
+	line("Type", info.ServiceType)
+	line("Callsign", info.Callsign)
+	line("Locator", info.Locator)
+	line("Event", info.EventName)
+	for _, s := range wrapText(info.EventText, kInfoLineChars) {
+		if y < 5 {
+			break
+		}
+		canvas.Text(25, y, kPanelTextSize, s, q100color.labelOrange)
+		y -= kPanelRowStep
+	}
+
+	return layout.Dimensions{
+		Size: image.Point{X: width, Y: height},
+	}
+}
+
+// Splits text into lines of no more than width characters, at spaces where possible
+func wrapText(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for len([]rune(word)) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				lines = append(lines, string([]rune(word)[:width]))
+				word = string([]rune(word)[width:])
+			}
+			switch {
+			case line == "":
+				line = word
+			case len([]rune(line))+1+len([]rune(word)) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,130 @@
+package lmClient
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/***********************************************************************
+*
+*	LNB DRIFT CALIBRATION FROM THE BEACON
+*
+*	The beacon is tuned and its carrier frequency, status id 6, is
+*	averaged over config_CalibrateSamples. The LNB LO is then the beacon
+*	frequency less the carrier frequency, and is used for all tunes and
+*	displayed frequencies until the next calibration.
+*
+************************************************************************/
+
+const (
+	config_LmLoFile          = config_LmBaseFolder + "lnb_lo_khz.txt"
+	config_CalibrateSamples  = 10
+	config_CalibrateTimeout  = 30 * time.Second
+	config_CalibrateMaxDrift = 500 // kHz from the nominal LO, beyond which the result is rejected
+
+	kBeaconFrequency  = "10491.50 / 00"
+	kBeaconSymbolRate = "1500"
+	kBeaconKHz        = 10491500
+)
+
+type calibration_t struct {
+	requestLoKHz  float64 // subtracted from a frequency to tune
+	receivedLoKHz float64 // added to status id 6 to display
+	isActive      bool
+	samples       []float64
+	deadline      time.Time
+	interval      time.Duration // 0 to calibrate only when asked
+	next          time.Time
+}
+
+var calibrateInterval time.Duration
+
+// Sets how often the LNB is calibrated on the beacon, when not tuned.
+// Must be called before ReadLonmyndStatus.
+func CalibrateEvery(interval time.Duration) {
+	calibrateInterval = interval
+}
+
+// Returns a calibration_t with the LO from the last calibration, or the configured offsets
+func newCalibration(now time.Time) calibration_t {
+	c := calibration_t{
+		requestLoKHz:  config_LmOffset_Reqested,
+		receivedLoKHz: config_LmOffset_Received,
+		interval:      calibrateInterval,
+		next:          now.Add(calibrateInterval),
+	}
+	if b, err := os.ReadFile(config_LmLoFile); err == nil {
+		if lo, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64); err == nil {
+			log.Printf("INFO LNB LO is %.3f MHz from the last calibration", lo/1000)
+			c.requestLoKHz = lo
+			c.receivedLoKHz = lo
+		}
+	}
+	return c
+}
+
+func (c *calibration_t) start(now time.Time) {
+	log.Printf("INFO calibrating on the beacon...")
+	c.isActive = true
+	c.samples = c.samples[:0]
+	c.deadline = now.Add(config_CalibrateTimeout)
+}
+
+func (c *calibration_t) stop(now time.Time) {
+	c.isActive = false
+	c.next = now.Add(c.interval)
+}
+
+// Adds a beacon carrier frequency from status id 6. Returns true when calibration has finished.
+func (c *calibration_t) addSample(carrierFrequencyStr string, now time.Time) bool {
+	kHz, err := strconv.ParseFloat(carrierFrequencyStr, 64)
+	if err != nil {
+		return false
+	}
+	c.samples = append(c.samples, kHz)
+	if len(c.samples) < config_CalibrateSamples {
+		return false
+	}
+	c.stop(now)
+
+	var sum float64
+	for _, s := range c.samples {
+		sum += s
+	}
+	lo := kBeaconKHz - sum/float64(len(c.samples))
+	if drift := lo - config_LmOffset_Reqested; drift > config_CalibrateMaxDrift || drift < -config_CalibrateMaxDrift {
+		log.Printf("WARN calibration rejected, LNB LO %.3f MHz is too far from nominal", lo/1000)
+		return true
+	}
+	log.Printf("INFO calibrated, LNB LO is %.3f MHz, was %.3f MHz", lo/1000, c.requestLoKHz/1000)
+	c.requestLoKHz = lo
+	c.receivedLoKHz = lo
+	if err := os.WriteFile(config_LmLoFile, []byte(fmt.Sprintf("%.3f\n", lo)), 0644); err != nil {
+		log.Printf("WARN failed to save the LNB LO: %v", err)
+	}
+	return true
+}
+
+// Returns true if calibration has timed out
+func (c *calibration_t) hasTimedOut(now time.Time) bool {
+	if c.isActive && now.After(c.deadline) {
+		log.Printf("WARN calibration failed, the beacon did not lock")
+		c.stop(now)
+		return true
+	}
+	return false
+}
+
+// Returns true if periodic calibration is due
+func (c *calibration_t) isDue(now time.Time) bool {
+	return !c.isActive && c.interval > 0 && now.After(c.next)
+}
+
+// Returns the LNB LO drift from nominal for display
+func (c *calibration_t) drift() string {
+	return fmt.Sprintf("%+.0f kHz", c.receivedLoKHz-config_LmOffset_Reqested)
+}
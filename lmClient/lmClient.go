@@ -6,7 +6,6 @@
 package lmClient
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
@@ -24,14 +23,15 @@ const (
 	config_LmStatusFifo      = config_LmBaseFolder + "longmynd/longmynd_main_status"
 	config_LmOffset_Received = float64(9750000 - 52) // only the displayed frequency
 	config_LmOffset_Reqested = float64(9750000 + 0)
+	config_LmHalfScanRatio   = 0.9 // longmynd -S, searched either side of the frequency as a ratio of the symbol rate, if not set by LmCmd_t
 
 	config_FpTsFifo = config_LmBaseFolder + "longmynd/longmynd_main_ts"
 	// config_FpBinary = "/usr/bin/ffplay"
 	config_FpVolume = "100"
 
-	CmdTune   = 1
-	CmdUnTune = 2
-	// CmdToggleCalibrate = 3
+	CmdTune            = 1
+	CmdUnTune          = 2
+	CmdToggleCalibrate = 3
 	// CmdEnableOffset  = 3
 	// CmdDisableOffset = 4
 )
@@ -40,8 +40,9 @@ type (
 	LmCmd_t struct {
 		Type          int
 		FrequencyStr  string
-		FrequencyKHz  float64
-		SymbolRateStr string
+		FrequencyKHz  float64 // if set, tune to this rather than FrequencyStr
+		SymbolRateStr string  // or comma-separated candidates to search
+		HalfScanRatio float64 // of the symbol rate to search either side of the frequency, or config_LmHalfScanRatio if 0
 	}
 
 	LmData_t struct {
@@ -63,6 +64,21 @@ type (
 		DbMargin      string
 		DbmPower      string
 		FreqOffset    string
+		FreqOffsetKHz float64 // from the requested frequency
+		FrequencyMHz  float64 // being trialled while searching, or locked
+		Resolution    string  // from the TS, ie. "1280x720"
+		FrameRate     string
+		VideoProfile  string // ie. "High@4.1"
+		TsKbps        string
+		VideoKbps     string
+		Bitrates      []PidBitrate_t // by PID, in order
+		Streams       []Stream_t     // from the status, in the order listed
+		ServiceInfo   ServiceInfo_t  // from the SDT and EIT in the TS
+		SymbolRateKS  float64
+		LnbDrift      string  // of the LO from nominal, found by calibration
+		LnbLoKHz      float64 // the LO, from calibration or else nominal
+		Calibrating   bool
+		Stopped       bool // the demodulator stopped by itself, so is no longer tuned
 		changed       bool
 		Locked        bool
 	}
@@ -84,57 +100,109 @@ func idAndValFromString(s string) (int, string, error) {
 
 ///////////////////////////////////////////////////////////////////////////////////////////
 
-// Reads the longmynd status fifo and translates to formated strings.
+// Reads the Demodulator status and translates to formated strings.
 //
 //	The results are sent to a channel of type LongmyndData. When no valid signal is being
 //	received, the LongmyndData fileds will be filled with default values - normally a dash.
 //
 // func ReadLonmyndStatus(ctx context.Context, lmc LmConfig_t, fpc FpConfig_t, ch chan LongmyndData) {
-func ReadLonmyndStatus(ctx context.Context, lmCmdChan <-chan LmCmd_t, lmDataChan chan<- LmData_t) {
+func ReadLonmyndStatus(ctx context.Context, demodulator Demodulator, lmCmdChan <-chan LmCmd_t, lmDataChan chan<- LmData_t) {
 
 	liveData := LmData_t{}
-	dependant := lmDependants_t{}
+	dependant := lmDependants_t{demodulator: demodulator, analyser: newTsAnalyser()}
+	calibration := newCalibration(time.Now())
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
 
 	liveData.reset()
+	liveData.LnbDrift = calibration.drift()
+	liveData.LnbLoKHz = calibration.receivedLoKHz
 	lmDataChan <- liveData
 
-	var reader *bufio.Reader = nil
+	var status <-chan string = nil // nil while not tuned
+
+	// stops the demodulator and sends the reset status
+	stop := func() {
+		dependant.stopFfPlayAndDemodulator()
+		status = nil
+		liveData.reset()
+		liveData.Calibrating = calibration.isActive
+		liveData.LnbDrift = calibration.drift()
+		liveData.LnbLoKHz = calibration.receivedLoKHz
+		lmDataChan <- liveData
+	}
+	tune := func(frequency string, frequencyKHz float64, symbolRate string, halfScanRatio float64) {
+		if dependant.isTuned { // retuning
+			stop()
+		}
+		dependant.startDemodulator(frequency, frequencyKHz, symbolRate, halfScanRatio, calibration.requestLoKHz)
+		if dependant.isTuned {
+			status = demodulator.Status()
+		}
+	}
+	calibrate := func(now time.Time) {
+		dependant.stopFfPlayAndDemodulator()
+		calibration.start(now)
+		tune(kBeaconFrequency, 0, kBeaconSymbolRate, 0)
+		if !dependant.isTuned {
+			calibration.stop(now)
+		}
+		liveData.reset()
+		liveData.Calibrating = calibration.isActive
+		lmDataChan <- liveData
+	}
 
 	for {
+		var rawStr string
+		var ok bool
+
 		select {
 		case <-ctx.Done():
-			dependant.stopFfPlayAndLongmynd()
+			dependant.stopFfPlayAndDemodulator()
 			log.Printf("CANCEL ----- lmClient has cancelled")
 			return
 		case cmd := <-lmCmdChan:
 			switch cmd.Type {
 			case CmdTune:
 				log.Printf("INFO ------ WILL TUNE")
-				dependant.startLongmynd(cmd.FrequencyStr, cmd.SymbolRateStr)
-				reader = bufio.NewReader(dependant.fifo)
+				if calibration.isActive {
+					log.Printf("INFO calibration cancelled")
+					calibration.stop(time.Now())
+					stop()
+				}
+				tune(cmd.FrequencyStr, cmd.FrequencyKHz, cmd.SymbolRateStr, cmd.HalfScanRatio)
 			case CmdUnTune:
 				log.Printf("INFO ------ WILL UNTUNE")
-				dependant.stopFfPlayAndLongmynd()
-				// case CmdToggleCalibrate:
-				// TODO: implement
+				stop()
+			case CmdToggleCalibrate:
+				if calibration.isActive {
+					log.Printf("INFO calibration cancelled")
+					calibration.stop(time.Now())
+					stop()
+				} else {
+					calibrate(time.Now())
+				}
 			}
-		default:
-		}
-
-		if !dependant.isTuned {
-			time.Sleep(time.Microsecond * 50)
-			liveData.reset()
-			lmDataChan <- liveData
 			continue
-		}
-
-		rawStr, err := reader.ReadString(10) // delimited by char(10) == LF
-		if err != nil {
-			log.Printf("ERROR reading fifo: %v", err)
-			liveData.reset()
-			lmDataChan <- liveData
-			time.Sleep(time.Millisecond * 50)
+		case now := <-ticker.C:
+			if dependant.isPlaying && liveData.Locked && dependant.analyser.update(&liveData) {
+				lmDataChan <- liveData
+			}
+			if calibration.hasTimedOut(now) {
+				stop()
+			} else if calibration.isDue(now) && !dependant.isTuned {
+				calibrate(now)
+			}
 			continue
+		case rawStr, ok = <-status:
+			if !ok {
+				log.Printf("ERROR demodulator status has closed")
+				calibration.stop(time.Now())
+				liveData.Stopped = true // kept by the reset in stop
+				stop()
+				liveData.Stopped = false
+				continue
+			}
 		}
 
 		lmId, lmVal, err := idAndValFromString(rawStr)
@@ -143,6 +211,10 @@ func ReadLonmyndStatus(ctx context.Context, lmCmdChan <-chan LmCmd_t, lmDataChan
 			continue
 		}
 
+		if lmId != 16 && lmId != 17 {
+			liveData.endEsRun()
+		}
+
 		switch lmId {
 		case 1: // State
 			liveData.id1_setState(lmVal)
@@ -157,7 +229,11 @@ func ReadLonmyndStatus(ctx context.Context, lmCmdChan <-chan LmCmd_t, lmDataChan
 		// case 4: // I Symbol Power - Measure of the current power being seen in the I symbols
 		// case 5: // Q Symbol Power - Measure of the current power being seen in the Q symbols
 		case 6: // Carrier Frequency - During a search this is the carrier frequency being trialled. When locked this is the Carrier Frequency detected in the stream. Sent in KHz
-			liveData.id6_setFrequency(lmVal, dependant.requestKHz) //, offset)
+			liveData.id6_setFrequency(lmVal, dependant.requestKHz, calibration.receivedLoKHz)
+			if calibration.isActive && liveData.Locked && calibration.addSample(lmVal, time.Now()) {
+				stop()
+				continue
+			}
 		// case 7: // I Constellation - Single signed byte representing the voltage of a sampled I point
 		// case 8: // Q Constellation - Single signed byte representing the voltage of a sampled Q point
 		case 9: // Symbol Rate - During a search this is the symbol rate being trialled.  When locked this is the symbol rate detected in the stream
@@ -193,7 +269,7 @@ func ReadLonmyndStatus(ctx context.Context, lmCmdChan <-chan LmCmd_t, lmDataChan
 
 		// TODO: the follwoing 4 if statement should be in a function in lmDependants.go
 
-		if dependant.isTuned && liveData.Locked && !dependant.isPlaying {
+		if dependant.isTuned && liveData.Locked && !dependant.isPlaying && !calibration.isActive {
 			dependant.startFfplay()
 		}
 		if dependant.isTuned && !liveData.Locked && dependant.isPlaying {
@@ -201,10 +277,15 @@ func ReadLonmyndStatus(ctx context.Context, lmCmdChan <-chan LmCmd_t, lmDataChan
 		}
 		if !dependant.isTuned && dependant.isPlaying {
 			liveData.Locked = false
-			dependant.stopFfPlayAndLongmynd()
+			dependant.stopFfPlayAndDemodulator()
 		}
 
-		if liveData.Locked {
+		if calibration.isActive {
+			liveData.StatusMsg = "Calibrating : " + liveData.State
+		} else if liveData.State == kSeaching && liveData.Frequency != kDash {
+			// the frequency and symbol rate being trialled
+			liveData.StatusMsg = fmt.Sprintf("%s : %s MHz : %s kS", liveData.State, liveData.Frequency, liveData.SymbolRate)
+		} else if liveData.Locked {
 			liveData.StatusMsg = fmt.Sprintf("%s : %s : %s", liveData.State, liveData.Provider, liveData.Service)
 		} else {
 			liveData.StatusMsg = liveData.State
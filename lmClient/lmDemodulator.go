@@ -0,0 +1,120 @@
+package lmClient
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+/***********************************************************************
+*
+*	DEMODULATORS
+*
+************************************************************************/
+
+type (
+	// A Demodulator tunes a receiver and provides its status and transport stream.
+	//
+	//	Status lines are in the longmynd format, ie. "$1,4\n". The Status
+	//	channel is closed after Untune, or if the Demodulator fails. The
+	//	symbolRate may be comma-separated candidates, ie. "333,250,500".
+	//	The carrier is searched for within halfScanRatio * the symbol rate
+	//	either side of requestKHz.
+	Demodulator interface {
+		Tune(requestKHz float64, symbolRate string, halfScanRatio float64) error
+		Untune()
+		Status() <-chan string
+		TS() (io.ReadCloser, error)
+	}
+
+	// longmynd driving a MiniTiouner
+	longmynd_t struct {
+		execCmd *exec.Cmd
+		fifo    *os.File
+		status  chan string
+		quit    chan struct{}
+	}
+)
+
+// Returns a Demodulator which runs longmynd from config_LmFolder
+func NewLongmynd() Demodulator {
+	return &longmynd_t{}
+}
+
+// Start longmynd and read its status fifo
+func (l *longmynd_t) Tune(requestKHz float64, symbolRate string, halfScanRatio float64) error {
+	requestKHzStr := strconv.FormatFloat(requestKHz, 'f', 0, 64)
+	halfScanStr := strconv.FormatFloat(halfScanRatio, 'f', -1, 64)
+
+	log.Printf("INFO longmynd will start...")
+	// l.execCmd = exec.Command("./longmynd", "-S", "0.6", requestKHzStr, symbolRate)
+	l.execCmd = exec.Command("./longmynd", "-S", halfScanStr, requestKHzStr, symbolRate)
+	l.execCmd.Dir = config_LmFolder // ie. /home/pi/Q100/longmynd/
+	if err := l.execCmd.Start(); err != nil {
+		return err
+	}
+	log.Printf("INFO longmynd has started with f = %v, halfscan = %v * SR", requestKHzStr, halfScanStr)
+
+	var err error
+	l.fifo, err = os.OpenFile(config_LmStatusFifo, os.O_RDONLY, os.ModeNamedPipe)
+	if err != nil {
+		log.Fatalf("FATAL Failed to open '%v' fifo %v: ", config_LmStatusFifo, err)
+	}
+	log.Printf("INFO fifo is open %v", l.fifo.Name())
+
+	l.status = make(chan string)
+	l.quit = make(chan struct{})
+	go readStatusLines(l.fifo, l.status, l.quit)
+	return nil
+}
+
+// Stop longmynd
+func (l *longmynd_t) Untune() {
+	log.Printf("INFO longmynd will stop...")
+	close(l.quit)
+	l.execCmd.Process.Kill()
+	l.execCmd.Process.Wait()
+	cmd := exec.Command("/usr/bin/pkill", "longmynd")
+	if err := cmd.Start(); err != nil {
+		log.Printf("ERROR failed to stop longmynd: %v", err)
+	} else {
+		cmd.Wait()
+	}
+	l.fifo.Close()
+	log.Printf("INFO longmynd has stopped")
+}
+
+func (l *longmynd_t) Status() <-chan string {
+	return l.status
+}
+
+// Opens the longmynd TS fifo
+func (l *longmynd_t) TS() (io.ReadCloser, error) {
+	return os.OpenFile(config_FpTsFifo, os.O_RDONLY, os.ModeNamedPipe)
+}
+
+// Sends each line read from r to status until r fails or quit is closed.
+// The status channel is closed on return.
+func readStatusLines(r io.Reader, status chan<- string, quit <-chan struct{}) {
+	defer close(status)
+	reader := bufio.NewReader(r)
+	for {
+		rawStr, err := reader.ReadString(10) // delimited by char(10) == LF
+		if err != nil {
+			select {
+			case <-quit:
+			default:
+				log.Printf("ERROR reading fifo: %v", err)
+			}
+			return
+		}
+		select {
+		case status <- rawStr:
+		case <-quit:
+			return
+		}
+	}
+}
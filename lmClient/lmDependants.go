@@ -1,8 +1,8 @@
 package lmClient
 
 import (
+	"io"
 	"log"
-	"os"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -14,72 +14,63 @@ import (
 *
 ************************************************************************/
 
+// The player, which reads the TS from stdin, and how to stop any strays. Tests replace these.
+var (
+	fpCommand     = []string{"/usr/bin/ffplay", "-left", "800", "-fs", "-volume", config_FpVolume, "-i", "pipe:0"}
+	fpKillCommand = []string{"/usr/bin/pkill", "ffplay"}
+)
+
 type (
 	lmDependants_t struct {
 		isPlaying      bool
 		isTuned        bool
 		ffPlayIsACtive bool
-		lmExecCmd      *exec.Cmd
+		demodulator    Demodulator
 		fpExecCmd      *exec.Cmd
-		fifo           *os.File
+		ts             io.ReadCloser
+		analyser       *tsAnalyser_t
 		requestKHz     float64
 	}
 )
 
-func (d *lmDependants_t) stopFfPlayAndLongmynd() {
+func (d *lmDependants_t) stopFfPlayAndDemodulator() {
 	if d.isPlaying {
 		d.stopFfplay()
 	}
 	if d.isTuned {
-		d.stopLongmynd()
+		d.stopDemodulator()
 	}
 }
 
-func (d *lmDependants_t) startLongmynd(frequency, symbolRate string) {
-	// trim "10491.50 / 00" to "10491.50"
-	frequencySplit := strings.SplitN(frequency, " ", 2)[0]
-	requestedFrequency, err := strconv.ParseFloat(frequencySplit, 64)
-	if err != nil {
-		log.Fatalf("FATAL bad frequency: %v", err)
+// Start the demodulator, where loKHz is the LNB LO, at frequencyKHz if set
+// or at frequency, searching halfScanRatio * the symbol rate either side
+func (d *lmDependants_t) startDemodulator(frequency string, frequencyKHz float64, symbolRate string, halfScanRatio, loKHz float64) {
+	if frequencyKHz == 0 {
+		// trim "10491.50 / 00" to "10491.50"
+		frequencySplit := strings.SplitN(frequency, " ", 2)[0]
+		requestedFrequency, err := strconv.ParseFloat(frequencySplit, 64)
+		if err != nil {
+			log.Fatalf("FATAL bad frequency: %v", err)
+		}
+		frequencyKHz = requestedFrequency * 1000
 	}
 
-	d.requestKHz = (requestedFrequency * 1000) - config_LmOffset_Reqested
-	requestKHzStr := strconv.FormatFloat(d.requestKHz, 'f', 0, 64)
+	d.requestKHz = frequencyKHz - loKHz
 
-	log.Printf("INFO longmynd will start...")
-	// d.lmExecCmd = exec.Command("./longmynd", "-S", "0.6", requestKHzStr, symbolRate)
-	d.lmExecCmd = exec.Command("./longmynd", "-S", "0.9", requestKHzStr, symbolRate) // removed -S
-	d.lmExecCmd.Dir = config_LmFolder                                                // ie. /home/pi/Q100/longmynd/
-	if err = d.lmExecCmd.Start(); err != nil {
-		log.Printf("ERROR failed to start longmynd: %v", err)
-		return
+	if halfScanRatio == 0 {
+		halfScanRatio = config_LmHalfScanRatio
 	}
-	log.Printf("INFO longmynd has started with f = %v", requestKHzStr)
-
-	d.fifo, err = os.OpenFile(config_LmStatusFifo, os.O_RDONLY, os.ModeNamedPipe)
-	if err != nil {
-		log.Fatalf("FATAL Failed to open '%v' fifo %v: ", config_LmStatusFifo, err)
+	if err := d.demodulator.Tune(d.requestKHz, symbolRate, halfScanRatio); err != nil {
+		log.Printf("ERROR failed to start demodulator: %v", err)
+		return
 	}
-	log.Printf("INFO fifo is open %v", d.fifo.Name())
 	d.isTuned = true
 }
 
-// Stop Longmynd
-func (d *lmDependants_t) stopLongmynd() {
-	// if d.isTuned {
-	log.Printf("INFO longmynd will stop...")
-	d.lmExecCmd.Process.Kill()
-	d.lmExecCmd.Process.Wait()
-	cmd := exec.Command("/usr/bin/pkill", "longmynd")
-	if err := cmd.Start(); err != nil {
-		log.Printf("ERROR failed to stop longmynd: %v", err)
-		return
-	}
-	cmd.Wait()
-	// }
-	log.Printf("INFO longmynd has stopped")
+// Stop the demodulator
+func (d *lmDependants_t) stopDemodulator() {
+	d.demodulator.Untune()
 	d.isTuned = false
-	d.fifo.Close() // TODO: should this higher up ?
 }
 
 // Start ffplay
@@ -107,10 +98,23 @@ func (d *lmDependants_t) startFfplay() {
 		// d.lmExecCmd.Env = append(d.lmExecCmd.Environ(), "DISPLAY=:0") // TODO: could this help?
 		// log.Printf("INFO: Env: %v", d.lmExecCmd.Env)
 
-		d.fpExecCmd = exec.Command("/usr/bin/ffplay", "-left", "800", "-fs", "-volume", config_FpVolume, "-i", config_FpTsFifo)
+		ts, err := d.demodulator.TS()
+		if err != nil {
+			log.Printf("ERROR failed to open TS: %v", err)
+			return
+		}
+		d.ts = ts
+
+		// the TS is read from stdin, so any Demodulator can feed ffplay,
+		// and a copy is analysed
+		d.analyser.reset()
+		d.fpExecCmd = exec.Command(fpCommand[0], fpCommand[1:]...)
+		d.fpExecCmd.Stdin = io.TeeReader(d.ts, d.analyser)
 
 		if err := d.fpExecCmd.Start(); err != nil {
 			log.Printf("ERROR failed to start ffplay: %v", err)
+			d.fpExecCmd = nil
+			d.ts.Close()
 			return
 		}
 		// cmd.Wait()
@@ -122,16 +126,18 @@ func (d *lmDependants_t) startFfplay() {
 
 // Stop ffplay
 func (d *lmDependants_t) stopFfplay() {
-	if d.isPlaying {
+	if d.isPlaying && d.fpExecCmd != nil {
 		log.Printf("INFO ffplay will stop...")
 		d.fpExecCmd.Process.Kill()
-		d.fpExecCmd.Process.Wait()
-		cmd := exec.Command("/usr/bin/pkill", "ffplay")
+		// unblocks the goroutine copying the TS to ffplay's stdin, so Wait returns
+		d.ts.Close()
+		d.fpExecCmd.Wait()
+		cmd := exec.Command(fpKillCommand[0], fpKillCommand[1:]...)
 		if err := cmd.Start(); err != nil {
 			log.Printf("ERROR failed to stop ffplay: %v", err)
-			return
+		} else {
+			cmd.Wait()
 		}
-		cmd.Wait()
 	}
 	log.Printf("INFO ffplay has stppoed")
 	d.ffPlayIsACtive = false
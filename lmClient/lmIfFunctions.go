@@ -3,6 +3,7 @@ package lmClient
 import (
 	"fmt"
 	"log"
+	"slices"
 	"strconv"
 )
 
@@ -21,15 +22,9 @@ const (
 )
 
 type (
-	esPairStuct struct { // TODO: too complicated - why not similar to agcPairStuct ?
-		waitingFor1stPid  bool
-		waitingFor2ndPid  bool
-		waitingFor1stType bool
-		waitingFor2ndType bool
-		the1stPidValue    string
-		the1stTypeValue   string
-		the2ndPidValue    string
-		the2ndTypeValue   string
+	esListStruct struct {
+		pending []Stream_t // id16 and id17 pairs so far in this status cycle
+		inRun   bool       // the last id was 16 or 17
 	}
 
 	agcPairStuct struct {
@@ -169,7 +164,7 @@ var (
 		{3200, -97},
 	}
 
-	esPair  = esPairStuct{}
+	esList  = esListStruct{}
 	agcPair = agcPairStuct{}
 )
 
@@ -177,15 +172,9 @@ var (
 	functions called from lmClient.go
 ***********************************************************/
 
-func (d *esPairStuct) reset() {
-	d.waitingFor1stPid = true
-	d.waitingFor2ndPid = false
-	d.waitingFor1stType = false
-	d.waitingFor2ndType = false
-	d.the1stPidValue = kDash
-	d.the1stTypeValue = kDash
-	d.the2ndPidValue = kDash
-	d.the2ndTypeValue = kDash
+func (d *esListStruct) reset() {
+	d.pending = d.pending[:0]
+	d.inRun = false
 }
 
 func (d *agcPairStuct) reset() {
@@ -222,10 +211,21 @@ func (d *LmData_t) resetPartial() {
 	d.DbMargin = kDash
 	d.DbmPower = kDash
 	d.FreqOffset = kDash
+	d.FreqOffsetKHz = 0
+	d.FrequencyMHz = 0
+	d.Resolution = kDash
+	d.FrameRate = kDash
+	d.VideoProfile = kDash
+	d.TsKbps = kDash
+	d.VideoKbps = kDash
+	d.Bitrates = nil
+	d.ServiceInfo = ServiceInfo_t{}
+	d.SymbolRateKS = 0
 	d.changed = true
 	// d.Locked =
 
-	esPair.reset()
+	d.Streams = nil
+	esList.reset()
 	agcPair.reset()
 }
 
@@ -257,18 +257,20 @@ func (d *LmData_t) id1_setState(stateStr string) {
 }
 
 // Carrier Frequency - During a search this is the carrier frequency being trialled. When locked this is the Carrier Frequency detected in the stream. Sent in KHz
-func (d *LmData_t) id6_setFrequency(carrierFrequencyStr string, requestedKHz float64) {
+func (d *LmData_t) id6_setFrequency(carrierFrequencyStr string, requestedKHz, loKHz float64) {
 	kHzFloat, err := strconv.ParseFloat(carrierFrequencyStr, 64)
 	if err != nil {
 		log.Printf("WARN Bad carrierFrequencyStr: %v", err)
 		d.Frequency = kDash
 		return
 	}
-	receivedFrequencyKHz := kHzFloat + config_LmOffset_Received
+	receivedFrequencyKHz := kHzFloat + loKHz
 	d.Frequency = fmt.Sprintf("%.3f", receivedFrequencyKHz/1000)
+	d.FrequencyMHz = receivedFrequencyKHz / 1000
 
 	frequencyErroorKHz := (kHzFloat - requestedKHz)
 	d.FreqOffset = fmt.Sprintf("%.3f", frequencyErroorKHz/1000)
+	d.FreqOffsetKHz = frequencyErroorKHz
 	d.changed = true
 }
 
@@ -282,6 +284,7 @@ func (d *LmData_t) id9_setSymbolRate(symbolRateStr string) {
 	}
 	sysmbolRate := sysmbolRateFloat / 1000.0
 	d.SymbolRate = fmt.Sprintf("%.1f", sysmbolRate)
+	d.SymbolRateKS = sysmbolRate
 	d.changed = true
 }
 
@@ -332,27 +335,21 @@ func (d *LmData_t) id15_setNullRatio(nullRatioStr string) {
 // The PID numbers themselves are fairly arbitrary, will vary based on the transmitted signal and don't really mean anything in a single program multiplex.
 func (d *LmData_t) id16_setEsPid(esPidStr string) {
 	// In the status stream 16 and 17 always come in pairs, 16 is the PID and 17 is the type for that PID, e.g.
-	// This means that PID 257 is of type 27 which you look up in the table to be H.264 and PID 258 is type 3 which the table says is MP3.
 	// $16,257 == PID 257 is of type 27 which you look up in the table to be H.264
 	// $17,27  meaning H.264
 	// $16,258 == PID 258 is type 3 which the table says is MP3
 	// $17,3   meaaning MP3
-	// The PID numbers themselves are fairly arbitrary, will vary based on the transmitted signal and don't really mean anything in a single program multiplex.
-
-	if esPair.waitingFor1stPid {
-		esPair.the1stPidValue = esPidStr
-		esPair.waitingFor1stPid = false
-		esPair.waitingFor2ndPid = false
-		esPair.waitingFor1stType = true
-		esPair.waitingFor2ndType = false
-	}
-	if esPair.waitingFor2ndPid {
-		esPair.the2ndPidValue = esPidStr
-		esPair.waitingFor1stPid = false
-		esPair.waitingFor2ndPid = false
-		esPair.waitingFor1stType = false
-		esPair.waitingFor2ndType = true
+	// Each status cycle repeats every pair, in any order and with any number of streams.
+	pid, err := strconv.Atoi(esPidStr)
+	if err != nil {
+		log.Printf("WARN Failed to convert esPid %v", err)
+		return
+	}
+	if !esList.inRun {
+		esList.pending = esList.pending[:0]
+		esList.inRun = true
 	}
+	esList.pending = append(esList.pending, Stream_t{Pid: pid, Type: -1})
 }
 
 // ES TYPE - Elementary Stream Type (repeated as pair with 16 for each ES)
@@ -362,58 +359,36 @@ func (d *LmData_t) id17_setEsType(esType string) {
 		log.Printf("WARN Failed to convert esType %v", err)
 		return
 	}
-	if esPair.waitingFor1stType {
-		esPair.the1stTypeValue = esType
-		esPair.waitingFor1stPid = false
-		esPair.waitingFor2ndPid = true
-		esPair.waitingFor1stType = false
-		esPair.waitingFor2ndType = false
-		d.PidPair1 = fmt.Sprintf("%v %v", esPair.the1stPidValue, esPair.the1stTypeValue) // beacon 257 27 = video
-		switch typ {
-		case 1:
-			d.VideoCodec = "MPEG1"
-		case 16:
-			d.VideoCodec = "H.263"
-		case 27:
-			d.VideoCodec = "H.264"
-		case 33:
-			d.VideoCodec = "JPG2K"
-		case 36:
-			d.VideoCodec = "H.265"
-		case 51:
-			d.VideoCodec = "H.266"
-		default:
-			d.VideoCodec = "???"
-		}
+	if n := len(esList.pending); n > 0 && esList.pending[n-1].Type < 0 {
+		esList.pending[n-1].Type = typ
+		esList.pending[n-1].Kind, esList.pending[n-1].Codec = streamType(typ)
 	}
-	if esPair.waitingFor2ndType {
-		esPair.the2ndTypeValue = esType
-		esPair.waitingFor1stPid = true
-		esPair.waitingFor2ndPid = false
-		esPair.waitingFor1stType = false
-		esPair.waitingFor2ndType = false
-		d.PidPair2 = fmt.Sprintf("%v %v", esPair.the2ndPidValue, esPair.the2ndTypeValue) // beacon 258 3 = audio
-		switch typ {
-		case 2:
-			d.AudioCodec = "MPEG2"
-		case 3:
-			d.AudioCodec = "MPA" // was "MP3"
-		case 4:
-			d.AudioCodec = "MP3"
-		case 6:
-			d.AudioCodec = "OPUS"
-		case 15:
-			d.AudioCodec = "ACC"
-		case 32:
-			d.AudioCodec = "MPA"
-		case 129:
-			d.AudioCodec = "AC3"
-		default:
-			d.AudioCodec = "???"
+}
+
+// Ends a run of id16 and id17 pairs, and updates the streams if they have changed
+func (d *LmData_t) endEsRun() {
+	if !esList.inRun {
+		return
+	}
+	esList.inRun = false
+	privateAudio(esList.pending)
+	if slices.Equal(esList.pending, d.Streams) {
+		return
+	}
+	d.Streams = slices.Clone(esList.pending) // sent to the UI, so never shared
+
+	d.PidPair1, d.PidPair2 = kDash, kDash
+	d.VideoCodec, d.AudioCodec = kDash, kDash
+	for _, es := range d.Streams {
+		switch {
+		case es.Kind == kStreamVideo && d.VideoCodec == kDash:
+			d.PidPair1 = fmt.Sprintf("%v %v", es.Pid, es.Type) // beacon 257 27 = video
+			d.VideoCodec = es.Codec
+		case es.Kind == kStreamAudio && d.AudioCodec == kDash:
+			d.PidPair2 = fmt.Sprintf("%v %v", es.Pid, es.Type) // beacon 258 3 = audio
+			d.AudioCodec = es.Codec
 		}
-		// TODO: should we reset esPair here ?
 	}
-
 	d.changed = true
 }
 
@@ -0,0 +1,41 @@
+package lmClient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Returns a signal report for reading back on air, ie.
+//
+//	"MER 8.4 dB, margin D 3.2, DVB-S2 QPSK 2/3, 333 kS, 10494.75"
+//
+// or false if not locked. Values not yet known are left out.
+func (d LmData_t) Report() (string, bool) {
+	if !d.Locked {
+		return "", false
+	}
+	known := func(s string) bool { return s != "" && s != kDash }
+	var parts []string
+	if known(d.DbMer) {
+		parts = append(parts, "MER "+d.DbMer+" dB")
+	}
+	if known(d.DbMargin) {
+		parts = append(parts, "margin "+d.DbMargin)
+	}
+	var modcod []string
+	for _, s := range []string{d.Mode, d.Constellation, d.Fec} {
+		if known(s) {
+			modcod = append(modcod, s)
+		}
+	}
+	if len(modcod) > 0 {
+		parts = append(parts, strings.Join(modcod, " "))
+	}
+	if d.SymbolRateKS > 0 {
+		parts = append(parts, fmt.Sprintf("%.0f kS", d.SymbolRateKS))
+	}
+	if d.FrequencyMHz > 0 {
+		parts = append(parts, fmt.Sprintf("%.2f", d.FrequencyMHz))
+	}
+	return strings.Join(parts, ", "), true
+}
@@ -0,0 +1,27 @@
+package lmClient
+
+import "testing"
+
+func TestReport(t *testing.T) {
+	var d LmData_t
+	d.resetPartial()
+	if _, ok := d.Report(); ok {
+		t.Errorf("report when not locked")
+	}
+
+	d.Locked = true
+	d.DbMer, d.DbMargin = "8.4", "D 3.2"
+	d.Mode, d.Constellation, d.Fec = kDVB_S2, "QPSK", "2/3"
+	d.SymbolRateKS, d.FrequencyMHz = 333, 10494.7512
+	want := "MER 8.4 dB, margin D 3.2, DVB-S2 QPSK 2/3, 333 kS, 10494.75"
+	if got, ok := d.Report(); !ok || got != want {
+		t.Errorf("report %q, want %q", got, want)
+	}
+
+	// just locked, so the MODCOD is not known yet
+	d.DbMargin, d.Constellation, d.Fec = kDash, kDash, kDash
+	want = "MER 8.4 dB, DVB-S2, 333 kS, 10494.75"
+	if got, _ := d.Report(); got != want {
+		t.Errorf("report %q, want %q", got, want)
+	}
+}
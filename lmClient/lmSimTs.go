@@ -0,0 +1,197 @@
+package lmClient
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+/***********************************************************************
+*
+*	SIMULATED TRANSPORT STREAM
+*
+*	Used by the simulator when it has no sample TS. One H.264 service,
+*	as sent by the QO-100 beacon, with the PAT, PMT and SDT, and a video
+*	PES for each frame holding an access unit delimiter, a 720p25 SPS
+*	and filler data. The TS is valid, so the analyser shows the
+*	resolution, frame rate and service, but has no pictures to decode.
+*
+************************************************************************/
+
+const (
+	config_SimFrameInterval = 40 * time.Millisecond // 25 fps
+	config_SimFillerBytes   = 2000                  // of each frame
+
+	kSimProgram     = 1
+	kSimPmtPid      = 0x100
+	kSimVideoPid    = 257
+	kSimPsiFrames   = 10 // between each PAT, PMT and SDT
+	kSimPtsStart    = kPtsHz
+	kSimPcrDelay    = kPtsHz / 10
+	kSimServiceType = 0x19 // H.264 HD TV
+	kSimProvider    = "A71A"
+	kSimService     = "QO-100 Beacon"
+	kNalAud         = 0x09
+	kNalFiller      = 0x0C
+	kStreamIdVideo  = 0xE0
+)
+
+// 1280x720 High@3.1 at 25 fps
+var simSps = []byte{
+	0x67, 0x64, 0x00, 0x1F, 0xAC, 0xD9, 0x40, 0x50, 0x05, 0xBB, 0x01, 0x10, 0x00,
+	0x00, 0x03, 0x00, 0x10, 0x00, 0x00, 0x03, 0x03, 0x20, 0xF1, 0x83, 0x19, 0x60,
+}
+
+type (
+	// An endless TS, paced at the frame rate, until it is closed
+	simTs_t struct {
+		frame     int64
+		cc        map[uint16]byte // continuity counter by PID
+		buf       []byte          // of the frame being read
+		ticker    *time.Ticker
+		done      chan struct{}
+		closeOnce sync.Once
+	}
+)
+
+// Returns a TS with a frame every interval
+func newSimTs(interval time.Duration) *simTs_t {
+	return &simTs_t{
+		cc:     map[uint16]byte{},
+		ticker: time.NewTicker(interval),
+		done:   make(chan struct{}),
+	}
+}
+
+// Blocks until the next frame is due. Returns io.EOF once closed.
+func (s *simTs_t) Read(p []byte) (int, error) {
+	select {
+	case <-s.done:
+		return 0, io.EOF
+	default:
+	}
+	for len(s.buf) == 0 {
+		select {
+		case <-s.done:
+			return 0, io.EOF
+		case <-s.ticker.C:
+			s.buf = s.nextFrame()
+		}
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+func (s *simTs_t) Close() error {
+	s.closeOnce.Do(func() {
+		s.ticker.Stop()
+		close(s.done)
+	})
+	return nil
+}
+
+// Returns the packets of the next frame, after the PSI when it is due
+func (s *simTs_t) nextFrame() []byte {
+	var ts []byte
+	if s.frame%kSimPsiFrames == 0 {
+		ts = append(ts, s.packets(kPidPat, psiSection(0x00, kSimProgram,
+			0, kSimProgram, 0xE0|kSimPmtPid>>8, kSimPmtPid&0xFF), -1)...)
+		ts = append(ts, s.packets(kSimPmtPid, psiSection(0x02, kSimProgram,
+			0xE0|kSimVideoPid>>8, kSimVideoPid&0xFF, 0xF0, 0,
+			kStreamTypeH264, 0xE0|kSimVideoPid>>8, kSimVideoPid&0xFF, 0xF0, 0), -1)...)
+		ts = append(ts, s.packets(kPidSdt, psiSection(kTableSdtActual, kSimProgram, simSdt()...), -1)...)
+	}
+
+	pts := kSimPtsStart + s.frame*kPtsHz*int64(config_SimFrameInterval)/int64(time.Second)
+	pes := []byte{0, 0, 1, kStreamIdVideo, 0, 0, 0x80, 0x80, 5,
+		byte(0x21 | pts>>29&0x0E), byte(pts >> 22), byte(pts>>14 | 1), byte(pts >> 7), byte(pts<<1 | 1)}
+	pes = append(pes, 0, 0, 0, 1, kNalAud, 0xF0)
+	pes = append(pes, 0, 0, 0, 1)
+	pes = append(pes, simSps...)
+	pes = append(pes, 0, 0, 0, 1, kNalFiller)
+	pes = append(pes, bytes.Repeat([]byte{0xFF}, config_SimFillerBytes)...)
+	pes = append(pes, 0x80)
+	ts = append(ts, s.packets(kSimVideoPid, pes, (pts-kSimPcrDelay)*300)...)
+
+	s.frame++
+	return ts
+}
+
+// Returns the service loop of the SDT
+func simSdt() []byte {
+	descriptor := []byte{kDescService, 0, kSimServiceType}
+	descriptor = append(descriptor, byte(len(kSimProvider)))
+	descriptor = append(descriptor, kSimProvider...)
+	descriptor = append(descriptor, byte(len(kSimService)))
+	descriptor = append(descriptor, kSimService...)
+	descriptor[1] = byte(len(descriptor) - 2)
+	sdt := []byte{0, 1, 0xFF, // original_network_id
+		0, kSimProgram, 0xFC, 0x80 | byte(len(descriptor)>>8), byte(len(descriptor))}
+	return append(sdt, descriptor...)
+}
+
+// Returns a PSI section for a packet payload, with its pointer field, length and CRC
+func psiSection(tableId byte, idExtension uint16, body ...byte) []byte {
+	section := []byte{0, tableId, 0, 0, byte(idExtension >> 8), byte(idExtension), 0xC1, 0, 0}
+	section = append(section, body...)
+	length := len(section[4:]) + 4 // after the length field, with the CRC
+	section[2], section[3] = 0xB0|byte(length>>8), byte(length)
+	crc := crc32Mpeg(section[1:])
+	return append(section, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+}
+
+// Splits payload into packets for pid, with the PCR in the first if pcr is
+// not negative, and stuffing in the adaptation field of the last
+func (s *simTs_t) packets(pid uint16, payload []byte, pcr int64) []byte {
+	var ts []byte
+	for first := true; first || len(payload) > 0; first = false {
+		pkt := []byte{kTsSync, byte(pid >> 8 & 0x1F), byte(pid), s.cc[pid] & 0x0F}
+		if first {
+			pkt[1] |= 0x40
+		}
+		s.cc[pid]++
+
+		var adaptation []byte
+		if first && pcr >= 0 {
+			base, extension := pcr/300, pcr%300
+			adaptation = []byte{0x10, byte(base >> 25), byte(base >> 17), byte(base >> 9), byte(base >> 1),
+				byte(base<<7) | 0x7E | byte(extension>>8), byte(extension)}
+		}
+		if adaptation == nil && len(payload) >= kTsPacketSize-4 {
+			pkt[3] |= 0x10 // payload only
+		} else {
+			if adaptation == nil {
+				adaptation = []byte{0x00} // no flags
+			}
+			room := kTsPacketSize - 4 - 1 - len(adaptation)
+			stuffing := room - min(len(payload), room)
+			pkt[3] |= 0x30 // adaptation and payload
+			pkt = append(pkt, byte(len(adaptation)+stuffing))
+			pkt = append(pkt, adaptation...)
+			pkt = append(pkt, bytes.Repeat([]byte{0xFF}, stuffing)...)
+		}
+		n := kTsPacketSize - len(pkt)
+		pkt = append(pkt, payload[:n]...)
+		payload = payload[n:]
+		ts = append(ts, pkt...)
+	}
+	return ts
+}
+
+// MPEG-2 CRC32 of a PSI section
+func crc32Mpeg(b []byte) uint32 {
+	crc := uint32(0xFFFFFFFF)
+	for _, v := range b {
+		crc ^= uint32(v) << 24
+		for range 8 {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
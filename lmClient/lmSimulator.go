@@ -0,0 +1,242 @@
+package lmClient
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+/***********************************************************************
+*
+*	SIMULATOR
+*
+*	Replays a longmynd status file and a sample TS, so the receiver can
+*	be developed and demonstrated without a MiniTiouner. The sample TS
+*	is paced by its PCR and looped. Without one, a minimal TS is
+*	generated (see lmSimTs.go).
+*
+*	Status files are named status_<symbolRate>.txt, ie. status_333.txt,
+*	with status.txt used for any other symbol rate. When searching several
+*	symbol rates, the first with a status file is used. They can be recorded
+*	with: cat /home/pi/Q100/longmynd/longmynd_main_status > status.txt
+*	The etc/sim/status.txt in the repo is not a recording, but written by
+*	hand in the same format, for a lock on the beacon.
+*
+************************************************************************/
+
+const (
+	config_SimTsFile       = "sample.ts"
+	config_SimLineInterval = 5 * time.Millisecond
+	config_SimTsKbps       = 1000 // pace of a sample TS until its first PCR
+
+	kPcrHz      = 27000000
+	kMaxPcrJump = 2 * kPcrHz // a larger jump, or going back, is a discontinuity
+)
+
+type (
+	simulator_t struct {
+		folder string
+		status chan string
+		quit   chan struct{}
+	}
+
+	// A TS file read in whole packets, paced by the PCR and started again at its end
+	pacedTs_t struct {
+		file      *os.File
+		reader    *bufio.Reader
+		pcrPid    int // of the first PCR, or -1 until one is found
+		basePcr   int64
+		start     time.Time // when basePcr, or the first byte until a PCR, was due
+		sent      int64     // bytes since start, for pacing before the first PCR
+		done      chan struct{}
+		closeOnce sync.Once
+	}
+)
+
+// Returns a Demodulator which replays the status files and sample TS in folder
+func NewSimulator(folder string) Demodulator {
+	return &simulator_t{folder: folder}
+}
+
+func (s *simulator_t) Tune(requestKHz float64, symbolRate string, halfScanRatio float64) error {
+	name := filepath.Join(s.folder, "status.txt")
+	for sr := range strings.SplitSeq(symbolRate, ",") {
+		candidate := filepath.Join(s.folder, "status_"+sr+".txt")
+		if _, err := os.Stat(candidate); err == nil {
+			name = candidate
+			break
+		}
+	}
+	lines, err := readLines(name)
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("%v is empty", name)
+	}
+	log.Printf("INFO simulator will replay %v", name)
+
+	s.status = make(chan string)
+	s.quit = make(chan struct{})
+	go replayLines(lines, s.status, s.quit)
+	return nil
+}
+
+func (s *simulator_t) Untune() {
+	close(s.quit)
+	log.Printf("INFO simulator has stopped")
+}
+
+func (s *simulator_t) Status() <-chan string {
+	return s.status
+}
+
+// Opens the sample TS, or else generates one
+func (s *simulator_t) TS() (io.ReadCloser, error) {
+	ts, err := openPacedTs(filepath.Join(s.folder, config_SimTsFile))
+	if err != nil {
+		log.Printf("INFO simulator has no sample TS, so will generate one: %v", err)
+		return newSimTs(config_SimFrameInterval), nil
+	}
+	return ts, nil
+}
+
+// Opens name, which must hold at least one TS packet
+func openPacedTs(name string) (*pacedTs_t, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := file.Stat(); err != nil || info.Size() < kTsPacketSize {
+		file.Close()
+		return nil, fmt.Errorf("%v has no TS packets", name)
+	}
+	log.Printf("INFO simulator will play %v", name)
+	t := &pacedTs_t{file: file, reader: bufio.NewReader(file), done: make(chan struct{})}
+	t.restart()
+	return t, nil
+}
+
+// Reads whole packets into p, up to a PCR that is not yet due, and then waits for it
+func (t *pacedTs_t) Read(p []byte) (int, error) {
+	if len(p) < kTsPacketSize {
+		return 0, io.ErrShortBuffer
+	}
+	n := 0
+	for n+kTsPacketSize <= len(p) {
+		pkt := p[n : n+kTsPacketSize]
+		if _, err := io.ReadFull(t.reader, pkt); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return n, err
+			}
+			if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+				return n, err
+			}
+			t.reader.Reset(t.file)
+			t.restart()
+			continue
+		}
+		n += kTsPacketSize
+		if due, ok := t.pcrDue(pkt); ok && time.Until(due) > 0 {
+			return n, t.waitUntil(due)
+		}
+	}
+	if t.pcrPid < 0 {
+		t.sent += int64(n)
+		return n, t.waitUntil(t.start.Add(time.Duration(t.sent * 8 * int64(time.Millisecond) / config_SimTsKbps)))
+	}
+	return n, nil
+}
+
+func (t *pacedTs_t) Close() error {
+	t.closeOnce.Do(func() { close(t.done) })
+	return t.file.Close()
+}
+
+// Starts the pacing again, at the start of the file
+func (t *pacedTs_t) restart() {
+	t.pcrPid = -1
+	t.basePcr = -1
+	t.start = time.Now()
+	t.sent = 0
+}
+
+// Returns when pkt is due, if it has a PCR on the PCR PID
+func (t *pacedTs_t) pcrDue(pkt []byte) (time.Time, bool) {
+	pid := int(pkt[1]&0x1F)<<8 | int(pkt[2])
+	if pkt[0] != kTsSync || pkt[3]&0x20 == 0 || pkt[4] < 7 || pkt[5]&0x10 == 0 || (t.pcrPid >= 0 && pid != t.pcrPid) {
+		return time.Time{}, false
+	}
+	base := int64(pkt[6])<<25 | int64(pkt[7])<<17 | int64(pkt[8])<<9 | int64(pkt[9])<<1 | int64(pkt[10])>>7
+	extension := (int64(pkt[10])&1)<<8 | int64(pkt[11])
+	pcr := base*300 + extension
+	if t.pcrPid >= 0 && pcr >= t.basePcr {
+		due := t.start.Add(time.Duration(float64(pcr-t.basePcr) / kPcrHz * float64(time.Second)))
+		if time.Until(due) <= kMaxPcrJump {
+			return due, true
+		}
+	}
+	// the first PCR, or a discontinuity
+	t.pcrPid = pid
+	t.basePcr = pcr
+	t.start = time.Now()
+	return t.start, true
+}
+
+// Waits until due, or returns io.EOF once closed
+func (t *pacedTs_t) waitUntil(due time.Time) error {
+	timer := time.NewTimer(time.Until(due))
+	defer timer.Stop()
+	select {
+	case <-t.done:
+		return io.EOF
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Returns every non-empty line in name, each terminated with a LF
+func readLines(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			lines = append(lines, line+"\n")
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// Sends lines to status repeatedly until quit is closed, then closes status
+func replayLines(lines []string, status chan<- string, quit <-chan struct{}) {
+	defer close(status)
+	ticker := time.NewTicker(config_SimLineInterval)
+	defer ticker.Stop()
+	for {
+		for _, line := range lines {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+			}
+			select {
+			case status <- line:
+			case <-quit:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package lmClient
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSimTs(t *testing.T) {
+	ts := newSimTs(time.Millisecond)
+	a := newTsAnalyser()
+	buf := make([]byte, 1000)
+	for ts.frame < 2*kPtsDeltas {
+		n, err := ts.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.Write(buf[:n])
+	}
+
+	var d LmData_t
+	d.reset()
+	a.update(&d)
+	if d.Resolution != "1280x720" || d.FrameRate != "25.00" || d.VideoProfile != "High@3.1" {
+		t.Errorf("got %v at %v fps, %v", d.Resolution, d.FrameRate, d.VideoProfile)
+	}
+	want := ServiceInfo_t{ServiceType: "H.264 HD TV", Provider: kSimProvider, Name: kSimService, Callsign: "A71A"}
+	if d.ServiceInfo != want {
+		t.Errorf("got service %+v, want %+v", d.ServiceInfo, want)
+	}
+	if a.streams[kSimVideoPid] != kStreamTypeH264 {
+		t.Errorf("got streams %v", a.streams)
+	}
+
+	ts.Close()
+	if _, err := ts.Read(buf); err != io.EOF {
+		t.Errorf("got %v after Close, want EOF", err)
+	}
+}
+
+func TestSimTsPackets(t *testing.T) {
+	ts := newSimTs(time.Millisecond)
+	defer ts.Close()
+	frame := ts.nextFrame()
+	if len(frame)%kTsPacketSize != 0 {
+		t.Fatalf("got %v bytes, not whole packets", len(frame))
+	}
+	cc := map[uint16]byte{}
+	for i := 0; i < len(frame); i += kTsPacketSize {
+		pkt := frame[i : i+kTsPacketSize]
+		pid := uint16(pkt[1]&0x1F)<<8 | uint16(pkt[2])
+		if pkt[0] != kTsSync || pkt[3]&0x0F != cc[pid] {
+			t.Fatalf("packet %v: bad sync or continuity % x", i/kTsPacketSize, pkt[:4])
+		}
+		cc[pid]++
+	}
+
+	// the CRC of a section including its CRC is 0
+	pat := psiSection(0x00, kSimProgram, 0, kSimProgram, 0xE0, 0)
+	if crc := crc32Mpeg(pat[1:]); crc != 0 {
+		t.Errorf("got CRC remainder %08x", crc)
+	}
+	// from a DVB PAT for program 1 on PMT PID 0x1000
+	if crc := crc32Mpeg(mustHex(t, "00b00d0001c100000001f000")); crc != 0x2ab104b2 {
+		t.Errorf("got CRC %08x, want 2ab104b2", crc)
+	}
+}
+
+// Runs ReadLonmyndStatus with demodulator until the test ends
+func runLmClient(t *testing.T, demodulator Demodulator) (chan<- LmCmd_t, <-chan LmData_t) {
+	ctx, cancel := context.WithCancel(context.Background())
+	lmCmdChan := make(chan LmCmd_t)
+	lmDataChan := make(chan LmData_t)
+	done := make(chan struct{})
+	go func() {
+		ReadLonmyndStatus(ctx, demodulator, lmCmdChan, lmDataChan)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		for {
+			select {
+			case <-done:
+				return
+			case <-lmDataChan:
+			}
+		}
+	})
+	return lmCmdChan, lmDataChan
+}
+
+// Replays status.txt and the generated TS through ReadLonmyndStatus
+func TestSimulatorReplay(t *testing.T) {
+	savedCommand, savedKill := fpCommand, fpKillCommand
+	fpCommand = []string{"/bin/sh", "-c", "cat > /dev/null"}
+	fpKillCommand = []string{"/bin/true"}
+	defer func() { fpCommand, fpKillCommand = savedCommand, savedKill }()
+
+	lmCmdChan, lmDataChan := runLmClient(t, NewSimulator("../etc/sim"))
+	<-lmDataChan // the reset status
+	lmCmdChan <- LmCmd_t{Type: CmdTune, FrequencyStr: "10491.50 / 00", SymbolRateStr: "1500"}
+
+	var d LmData_t
+	timeout := time.After(10 * time.Second)
+	for !d.Locked || d.Resolution == kDash || d.FrameRate != "25.00" {
+		select {
+		case d = <-lmDataChan:
+		case <-timeout:
+			t.Fatalf("timed out with %+v", d)
+		}
+	}
+
+	for _, v := range []struct{ name, got, want string }{
+		{"State", d.State, kLocked}, {"Mode", d.Mode, kDVB_S2},
+		{"SymbolRate", d.SymbolRate, "1500.0"},
+		{"Provider", d.Provider, "A71A"},
+		{"Service", d.Service, "QO-100 Beacon"},
+		{"Constellation", d.Constellation, "QPSK"},
+		{"Fec", d.Fec, "4/5"},
+		{"Resolution", d.Resolution, "1280x720"},
+		{"VideoProfile", d.VideoProfile, "High@3.1"},
+	} {
+		if v.got != v.want {
+			t.Errorf("%v: got %q, want %q", v.name, v.got, v.want)
+		}
+	}
+	if !slices.Contains([]string{"9.1", "9.2", "9.3", "9.4", "9.5"}, d.DbMer) {
+		t.Errorf("got MER %v, want one recorded", d.DbMer)
+	}
+	if d.FrequencyMHz < 10491.4 || d.FrequencyMHz > 10491.6 {
+		t.Errorf("got %v MHz", d.FrequencyMHz)
+	}
+	wantStreams := []Stream_t{{Pid: 257, Type: 27}, {Pid: 258, Type: 3}}
+	if !slices.EqualFunc(d.Streams, wantStreams, func(x, y Stream_t) bool { return x.Pid == y.Pid && x.Type == y.Type }) {
+		t.Errorf("got streams %+v, want %+v", d.Streams, wantStreams)
+	}
+}
+
+// A demodulator whose status closes when told to
+type closingDemodulator_t struct {
+	status chan string
+}
+
+func (d *closingDemodulator_t) Tune(requestKHz float64, symbolRate string, halfScanRatio float64) error {
+	return nil
+}
+func (d *closingDemodulator_t) Untune()                    {}
+func (d *closingDemodulator_t) Status() <-chan string      { return d.status }
+func (d *closingDemodulator_t) TS() (io.ReadCloser, error) { return nil, io.ErrClosedPipe }
+
+func TestStatusClosed(t *testing.T) {
+	demodulator := &closingDemodulator_t{status: make(chan string)}
+	lmCmdChan, lmDataChan := runLmClient(t, demodulator)
+	<-lmDataChan // the reset status
+	lmCmdChan <- LmCmd_t{Type: CmdTune, FrequencyStr: "10491.50 / 00", SymbolRateStr: "1500"}
+	demodulator.status <- "$1,1\n" // searching
+	if d := <-lmDataChan; d.Stopped || d.Locked {
+		t.Fatalf("got %+v while searching", d)
+	}
+
+	close(demodulator.status)
+	select {
+	case d := <-lmDataChan:
+		if !d.Stopped || d.Locked || d.Calibrating || d.StatusMsg != "Not tuned" {
+			t.Errorf("got %+v, want stopped", d)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("nothing sent when the status closed")
+	}
+}
+
+// Writes packets to a sample TS and returns its name
+func sampleTs(t *testing.T, packets []byte) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), config_SimTsFile)
+	if err := os.WriteFile(name, packets, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+// Returns how long it takes to read size bytes from ts
+func readTime(t *testing.T, ts io.Reader, size int) time.Duration {
+	t.Helper()
+	start := time.Now()
+	buf := make([]byte, 32*1024)
+	for read := 0; read < size; {
+		n, err := ts.Read(buf[:min(len(buf), size-read)])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n%kTsPacketSize != 0 {
+			t.Fatalf("read %v bytes, not whole packets", n)
+		}
+		read += n
+	}
+	return time.Since(start)
+}
+
+func TestPacedTs(t *testing.T) {
+	const frames = 10
+	sim := newSimTs(time.Millisecond)
+	var packets []byte
+	for range frames {
+		packets = append(packets, sim.nextFrame()...)
+	}
+	sim.Close()
+	ts, err := openPacedTs(sampleTs(t, packets))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	// paced by the PCR, a frame every 40 ms, and looped
+	want := (frames - 1) * config_SimFrameInterval
+	if got := readTime(t, ts, len(packets)); got < want-10*time.Millisecond || got > want+100*time.Millisecond {
+		t.Errorf("took %v, want %v", got, want)
+	}
+	if got := readTime(t, ts, len(packets)); got < want-10*time.Millisecond || got > want+100*time.Millisecond {
+		t.Errorf("took %v to loop, want %v", got, want)
+	}
+
+	ts.Close()
+	if _, err := ts.Read(make([]byte, kTsPacketSize)); err == nil {
+		t.Errorf("read after Close")
+	}
+}
+
+func TestPacedTsWithoutPcr(t *testing.T) {
+	packets := make([]byte, 0, 50*kTsPacketSize)
+	for range 50 {
+		packets = append(packets, tsPacket(kTestVideoPid, false, nil)...)
+	}
+	ts, err := openPacedTs(sampleTs(t, packets))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	want := time.Duration(len(packets)*8) * time.Millisecond / config_SimTsKbps
+	if got := readTime(t, ts, len(packets)); got < want-10*time.Millisecond || got > want+100*time.Millisecond {
+		t.Errorf("took %v, want %v", got, want)
+	}
+
+	if _, err := openPacedTs(sampleTs(t, packets[:kTsPacketSize-1])); err == nil {
+		t.Errorf("opened a TS without a whole packet")
+	}
+}
+
+// A demodulator whose TS blocks until it is closed
+type pipeDemodulator_t struct {
+	closingDemodulator_t
+	ts *io.PipeReader
+}
+
+func (d *pipeDemodulator_t) TS() (io.ReadCloser, error) { return d.ts, nil }
+
+func TestStopFfplayWithoutPkill(t *testing.T) {
+	savedCommand, savedKill := fpCommand, fpKillCommand
+	fpCommand = []string{"/bin/sh", "-c", "cat > /dev/null"}
+	fpKillCommand = []string{"/nonexistent/pkill"}
+	defer func() { fpCommand, fpKillCommand = savedCommand, savedKill }()
+
+	r, w := io.Pipe()
+	defer w.Close()
+	d := &lmDependants_t{demodulator: &pipeDemodulator_t{ts: r}, analyser: newTsAnalyser()}
+	d.startFfplay()
+	if !d.isPlaying {
+		t.Fatalf("ffplay not started")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		d.stopFfplay()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("stopFfplay did not return")
+	}
+	if d.isPlaying || d.ffPlayIsACtive {
+		t.Errorf("still playing after stopFfplay")
+	}
+	if _, err := w.Write([]byte{kTsSync}); err != io.ErrClosedPipe {
+		t.Errorf("got %v writing the TS, want it closed", err)
+	}
+}
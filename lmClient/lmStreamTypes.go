@@ -0,0 +1,122 @@
+package lmClient
+
+import "slices"
+
+/***********************************************************************
+*
+*	MPEG-TS STREAM TYPES
+*
+************************************************************************/
+
+const (
+	kStreamOther = "Other"
+	kStreamVideo = "Video"
+	kStreamAudio = "Audio"
+	kStreamData  = "Data"
+
+	kStreamTypePrivatePes = 0x06
+)
+
+type (
+	// An elementary stream, from a status id16 and id17 pair
+	Stream_t struct {
+		Pid   int
+		Type  int    // MPEG-TS stream type, ie. 27
+		Kind  string // ie. "Video"
+		Codec string // ie. "H.264"
+	}
+
+	streamType_t struct {
+		kind  string
+		codec string
+	}
+)
+
+// Stream types 0x01 to 0x34 from ISO/IEC 13818-1 Table 2-34, with the common
+// uses of user private 0x80 and above: Blu-ray LPCM, DTS, TrueHD and PGS, ATSC AC3
+// and E-AC3, SCTE-35, and SMPTE VC-1 and Dirac
+var const_StreamTypes = map[int]streamType_t{
+	0x01: {kStreamVideo, "MPEG-1"},
+	0x02: {kStreamVideo, "MPEG-2"},
+	0x03: {kStreamAudio, "MPA"}, // MPEG-1 Layer 1, 2 or 3
+	0x04: {kStreamAudio, "MPA"}, // MPEG-2 Layer 1, 2 or 3
+	0x05: {kStreamData, "Private"},
+	0x06: {kStreamOther, "Private"}, // PES private data, ie. subtitles or teletext, or Opus audio, see privateAudio
+	0x07: {kStreamData, "MHEG"},
+	0x08: {kStreamData, "DSM-CC"},
+	0x09: {kStreamData, "H.222.1"},
+	0x0A: {kStreamData, "DSM-CC"},
+	0x0B: {kStreamData, "DSM-CC"},
+	0x0C: {kStreamData, "DSM-CC"},
+	0x0D: {kStreamData, "DSM-CC"},
+	0x0E: {kStreamData, "Aux"},
+	0x0F: {kStreamAudio, "AAC"}, // ADTS
+	0x10: {kStreamVideo, "MPEG-4"},
+	0x11: {kStreamAudio, "AAC"}, // LATM
+	0x12: {kStreamData, "MPEG-4 SL"},
+	0x13: {kStreamData, "MPEG-4 SL"},
+	0x14: {kStreamData, "DSM-CC"},
+	0x15: {kStreamData, "Metadata"}, // ie. KLV
+	0x16: {kStreamData, "Metadata"},
+	0x17: {kStreamData, "Metadata"},
+	0x18: {kStreamData, "Metadata"},
+	0x19: {kStreamData, "Metadata"},
+	0x1A: {kStreamData, "IPMP"},
+	0x1B: {kStreamVideo, "H.264"},
+	0x1C: {kStreamAudio, "MPEG-4"},
+	0x1D: {kStreamData, "Text"},
+	0x1E: {kStreamVideo, "Aux"},
+	0x1F: {kStreamVideo, "H.264 SVC"},
+	0x20: {kStreamVideo, "H.264 MVC"},
+	0x21: {kStreamVideo, "JPG2K"},
+	0x22: {kStreamVideo, "MPEG-2 3D"},
+	0x23: {kStreamVideo, "H.264 3D"},
+	0x24: {kStreamVideo, "H.265"},
+	0x25: {kStreamVideo, "H.265"}, // temporal subset
+	0x26: {kStreamVideo, "H.264 MVCD"},
+	0x27: {kStreamData, "TEMI"},   // timeline and external media information
+	0x28: {kStreamVideo, "H.265"}, // enhancement sub-partition
+	0x29: {kStreamVideo, "H.265"}, // temporal enhancement sub-partition
+	0x2A: {kStreamVideo, "H.265"}, // enhancement sub-partition, Annex H
+	0x2B: {kStreamVideo, "H.265"}, // temporal enhancement sub-partition, Annex H
+	0x2C: {kStreamData, "Green"},  // green access units, in sections
+	0x2D: {kStreamAudio, "MPEG-H"},
+	0x2E: {kStreamAudio, "MPEG-H"},       // auxiliary
+	0x2F: {kStreamData, "Quality"},       // quality access units, in sections
+	0x30: {kStreamData, "Orchestration"}, // media orchestration access units, in sections
+	0x31: {kStreamVideo, "H.265 tiles"},
+	0x32: {kStreamVideo, "JPEG XS"},
+	0x33: {kStreamVideo, "H.266"},
+	0x34: {kStreamVideo, "H.266"}, // subset
+	0x80: {kStreamAudio, "LPCM"},
+	0x81: {kStreamAudio, "AC3"},
+	0x82: {kStreamAudio, "DTS"},
+	0x83: {kStreamAudio, "TrueHD"},
+	0x84: {kStreamAudio, "E-AC3"},
+	0x85: {kStreamAudio, "DTS-HD"},
+	0x86: {kStreamData, "SCTE-35"},
+	0x87: {kStreamAudio, "E-AC3"},
+	0x90: {kStreamData, "PGS"},
+	0xD1: {kStreamVideo, "Dirac"},
+	0xEA: {kStreamVideo, "VC-1"},
+}
+
+// Shows the first PES private data stream as Opus audio if there is no other
+// audio, as DATV encoders carry Opus this way, and subtitles and teletext
+// come with audio of their own
+func privateAudio(streams []Stream_t) {
+	if slices.ContainsFunc(streams, func(es Stream_t) bool { return es.Kind == kStreamAudio }) {
+		return
+	}
+	if i := slices.IndexFunc(streams, func(es Stream_t) bool { return es.Type == kStreamTypePrivatePes }); i >= 0 {
+		streams[i].Kind, streams[i].Codec = kStreamAudio, "OPUS"
+	}
+}
+
+// Returns the kind and codec name of an MPEG-TS stream type
+func streamType(typ int) (string, string) {
+	if st, ok := const_StreamTypes[typ]; ok {
+		return st.kind, st.codec
+	}
+	return kStreamOther, "???"
+}
@@ -0,0 +1,86 @@
+package lmClient
+
+import "testing"
+
+func TestStreamType(t *testing.T) {
+	tests := []struct {
+		typ         int
+		kind, codec string
+	}{
+		{0x02, kStreamVideo, "MPEG-2"},
+		{0x03, kStreamAudio, "MPA"},
+		{0x06, kStreamOther, "Private"},
+		{0x0F, kStreamAudio, "AAC"},
+		{0x15, kStreamData, "Metadata"},
+		{0x1B, kStreamVideo, "H.264"},
+		{0x24, kStreamVideo, "H.265"},
+		{0x27, kStreamData, "TEMI"},
+		{0x2A, kStreamVideo, "H.265"},
+		{0x2E, kStreamAudio, "MPEG-H"},
+		{0x31, kStreamVideo, "H.265 tiles"},
+		{0x81, kStreamAudio, "AC3"},
+		{0x86, kStreamData, "SCTE-35"},
+		{0x7F, kStreamOther, "???"},
+	}
+	for _, tt := range tests {
+		if kind, codec := streamType(tt.typ); kind != tt.kind || codec != tt.codec {
+			t.Errorf("type %#x is %v %v, want %v %v", tt.typ, kind, codec, tt.kind, tt.codec)
+		}
+	}
+}
+
+func TestPrivateAudio(t *testing.T) {
+	var d LmData_t
+	d.resetPartial()
+	// H.264 video with Opus audio, as sent by DATV encoders
+	for _, pair := range [][2]string{{"256", "27"}, {"257", "6"}} {
+		d.id16_setEsPid(pair[0])
+		d.id17_setEsType(pair[1])
+	}
+	d.endEsRun()
+	if d.PidPair2 != "257 6" || d.AudioCodec != "OPUS" || d.Streams[1].Kind != kStreamAudio {
+		t.Errorf("audio %q %q, streams %+v, want \"257 6\" \"OPUS\"", d.PidPair2, d.AudioCodec, d.Streams)
+	}
+
+	// MPA audio with subtitles
+	for _, pair := range [][2]string{{"256", "27"}, {"257", "3"}, {"258", "6"}} {
+		d.id16_setEsPid(pair[0])
+		d.id17_setEsType(pair[1])
+	}
+	d.endEsRun()
+	if d.PidPair2 != "257 3" || d.AudioCodec != "MPA" || d.Streams[2].Kind != kStreamOther {
+		t.Errorf("audio %q %q, streams %+v, want \"257 3\" \"MPA\"", d.PidPair2, d.AudioCodec, d.Streams)
+	}
+}
+
+func TestStreamTypesComplete(t *testing.T) {
+	for typ := 0x01; typ <= 0x34; typ++ {
+		if _, ok := const_StreamTypes[typ]; !ok {
+			t.Errorf("type %#x is missing", typ)
+		}
+	}
+}
+
+func TestEsRun(t *testing.T) {
+	var d LmData_t
+	d.resetPartial()
+	// KLV data first, then audio before video
+	for _, pair := range [][2]string{{"256", "21"}, {"258", "15"}, {"257", "36"}} {
+		d.id16_setEsPid(pair[0])
+		d.id17_setEsType(pair[1])
+	}
+	d.endEsRun()
+
+	if len(d.Streams) != 3 {
+		t.Fatalf("%v streams, want 3", len(d.Streams))
+	}
+	if d.Streams[0].Kind != kStreamData {
+		t.Errorf("first stream is %v, want %v", d.Streams[0].Kind, kStreamData)
+	}
+	if d.PidPair1 != "257 36" || d.VideoCodec != "H.265" {
+		t.Errorf("video %q %q, want \"257 36\" \"H.265\"", d.PidPair1, d.VideoCodec)
+	}
+	if d.PidPair2 != "258 15" || d.AudioCodec != "AAC" {
+		t.Errorf("audio %q %q, want \"258 15\" \"AAC\"", d.PidPair2, d.AudioCodec)
+	}
+}
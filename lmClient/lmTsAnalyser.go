@@ -0,0 +1,368 @@
+package lmClient
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+/***********************************************************************
+*
+*	TRANSPORT STREAM ANALYSIS
+*
+*	A copy of the TS sent to ffplay is parsed for the PAT and PMT, to
+*	find the video PID and its stream type, and for the video PES, to
+*	find the SPS and the PTS of each frame, and for the SDT and EIT.
+*	Bytes are counted per PID for the bitrates.
+*
+************************************************************************/
+
+const (
+	config_TsBitrateInterval = time.Second
+
+	kTsPacketSize   = 188
+	kTsSync         = 0x47
+	kPidPat         = 0
+	kPidNull        = 0x1FFF
+	kStreamTypeH264 = 0x1B
+	kStreamTypeH265 = 0x24
+	kPesScanBytes   = 8192 // of each video PES searched for an SPS
+	kPtsDeltas      = 32   // used for the frame rate
+	kPtsHz          = 90000
+)
+
+type (
+	PidBitrate_t struct {
+		Pid  uint16
+		Kbps float64
+	}
+
+	// Parses the TS written to it. Safe for concurrent use.
+	tsAnalyser_t struct {
+		mu       sync.Mutex
+		partial  []byte
+		sections map[uint16][]byte // PSI sections being reassembled, by PID
+		pmtPids  map[uint16]bool
+		program  uint16          // the first in the PAT, which is the service
+		streams  map[uint16]byte // stream type by PID, from the PMT
+
+		videoPid  uint16
+		videoType byte
+		pes       []byte
+		lastSps   []byte
+		lastPts   int64
+		ptsDeltas []int64
+
+		counts      map[uint16]int // bytes by PID since windowStart
+		windowStart time.Time
+
+		format    videoFormat_t
+		service   ServiceInfo_t
+		bitrates  []PidBitrate_t
+		totalKbps float64
+		changed   bool
+	}
+)
+
+func newTsAnalyser() *tsAnalyser_t {
+	a := &tsAnalyser_t{}
+	a.reset()
+	return a
+}
+
+// Forgets everything, for a new TS
+func (a *tsAnalyser_t) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.partial = a.partial[:0]
+	a.sections = map[uint16][]byte{}
+	a.pmtPids = map[uint16]bool{}
+	a.program = 0
+	a.streams = map[uint16]byte{}
+	a.videoPid = 0
+	a.videoType = 0
+	a.pes = nil
+	a.lastSps = nil
+	a.lastPts = -1
+	a.ptsDeltas = nil
+	a.counts = map[uint16]int{}
+	a.windowStart = time.Now()
+	a.format = videoFormat_t{}
+	a.service = ServiceInfo_t{}
+	a.bitrates = nil
+	a.totalKbps = 0
+	a.changed = true
+}
+
+// Parses TS packets, keeping any partial packet for the next Write. Never fails,
+// so it can be used with io.TeeReader.
+func (a *tsAnalyser_t) Write(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.partial = append(a.partial, p...)
+	buf := a.partial
+	for len(buf) >= kTsPacketSize {
+		if buf[0] != kTsSync {
+			// lost sync, so skip to the next sync byte
+			i := bytes.IndexByte(buf[1:], kTsSync)
+			if i < 0 {
+				buf = buf[len(buf):]
+				break
+			}
+			buf = buf[1+i:]
+			continue
+		}
+		a.packet(buf[:kTsPacketSize])
+		buf = buf[kTsPacketSize:]
+	}
+	a.partial = append(a.partial[:0], buf...)
+
+	if now := time.Now(); now.Sub(a.windowStart) >= config_TsBitrateInterval {
+		a.measureBitrates(now)
+	}
+	return len(p), nil
+}
+
+func (a *tsAnalyser_t) packet(pkt []byte) {
+	pid := uint16(pkt[1]&0x1F)<<8 | uint16(pkt[2])
+	a.counts[pid] += kTsPacketSize
+	if pkt[1]&0x80 != 0 || pid == kPidNull { // transport error or null
+		return
+	}
+	pusi := pkt[1]&0x40 != 0
+	adaptation := pkt[3] >> 4 & 3
+	if adaptation&1 == 0 { // no payload
+		return
+	}
+	start := 4
+	if adaptation&2 != 0 {
+		start += 1 + int(pkt[4])
+	}
+	if start >= kTsPacketSize {
+		return
+	}
+	payload := pkt[start:]
+
+	switch {
+	case pid == kPidPat || pid == kPidSdt || pid == kPidEit || a.pmtPids[pid]:
+		a.psi(pid, pusi, payload)
+	case pid == a.videoPid:
+		a.video(pusi, payload)
+	}
+}
+
+// Reassembles a PSI section and parses it when complete
+func (a *tsAnalyser_t) psi(pid uint16, pusi bool, payload []byte) {
+	section := a.sections[pid]
+	if pusi {
+		pointer := int(payload[0])
+		if 1+pointer >= len(payload) {
+			return
+		}
+		section = append(section[:0], payload[1+pointer:]...)
+	} else if len(section) > 0 {
+		section = append(section, payload...)
+	} else {
+		return
+	}
+	a.sections[pid] = section
+
+	if len(section) < 3 {
+		return
+	}
+	length := 3 + (int(section[1]&0x0F)<<8 | int(section[2]))
+	if len(section) < length {
+		return
+	}
+	section = section[:length]
+	switch section[0] {
+	case 0x00:
+		a.pat(section)
+	case 0x02:
+		a.pmt(section)
+	case kTableSdtActual:
+		a.sdt(section)
+	case kTableEitActualPf:
+		a.eit(section)
+	}
+	a.sections[pid] = a.sections[pid][:0]
+}
+
+// Program Association Table
+func (a *tsAnalyser_t) pat(section []byte) {
+	for i := 8; i+4 <= len(section)-4; i += 4 {
+		program := uint16(section[i])<<8 | uint16(section[i+1])
+		pid := uint16(section[i+2]&0x1F)<<8 | uint16(section[i+3])
+		if program != 0 { // 0 is the NIT
+			a.pmtPids[pid] = true
+			if a.program == 0 {
+				a.program = program
+			}
+		}
+	}
+}
+
+// Program Map Table
+func (a *tsAnalyser_t) pmt(section []byte) {
+	if len(section) < 12 {
+		return
+	}
+	end := len(section) - 4 // CRC
+	i := 12 + (int(section[10]&0x0F)<<8 | int(section[11]))
+	for i+5 <= end {
+		streamType := section[i]
+		pid := uint16(section[i+1]&0x1F)<<8 | uint16(section[i+2])
+		if a.streams[pid] != streamType {
+			a.changed = true
+		}
+		a.streams[pid] = streamType
+		if (streamType == kStreamTypeH264 || streamType == kStreamTypeH265) && pid != a.videoPid {
+			a.videoPid = pid
+			a.videoType = streamType
+			a.pes = nil
+			a.lastSps = nil
+			a.lastPts = -1
+			a.ptsDeltas = nil
+			a.format = videoFormat_t{}
+		}
+		i += 5 + (int(section[i+3]&0x0F)<<8 | int(section[i+4]))
+	}
+}
+
+// Collects the start of each video PES, and its PTS
+func (a *tsAnalyser_t) video(pusi bool, payload []byte) {
+	if !pusi {
+		if a.pes != nil && len(a.pes) < kPesScanBytes {
+			a.pes = append(a.pes, payload[:min(len(payload), kPesScanBytes-len(a.pes))]...)
+		}
+		return
+	}
+	if a.pes != nil {
+		a.findSps(a.pes)
+	}
+	a.pes = nil
+	if len(payload) < 9 || payload[0] != 0 || payload[1] != 0 || payload[2] != 1 {
+		return
+	}
+	headerEnd := 9 + int(payload[8])
+	if payload[7]&0x80 != 0 && len(payload) >= 14 {
+		a.addPts(int64(payload[9]>>1&0x07)<<30 | int64(payload[10])<<22 | int64(payload[11]>>1)<<15 |
+			int64(payload[12])<<7 | int64(payload[13]>>1))
+	}
+	if headerEnd < len(payload) {
+		a.pes = append(make([]byte, 0, kPesScanBytes), payload[headerEnd:]...)
+	}
+}
+
+func (a *tsAnalyser_t) addPts(pts int64) {
+	if a.lastPts >= 0 {
+		delta := (pts - a.lastPts + 1<<33) % (1 << 33)
+		if delta > 0 && delta <= kPtsHz {
+			a.ptsDeltas = append(a.ptsDeltas, delta)
+			if len(a.ptsDeltas) > kPtsDeltas {
+				a.ptsDeltas = a.ptsDeltas[1:]
+			}
+		}
+	}
+	a.lastPts = pts
+}
+
+// Finds and parses an SPS in the start of a video PES
+func (a *tsAnalyser_t) findSps(es []byte) {
+	for i := 0; i+3 < len(es); i++ {
+		if es[i] != 0 || es[i+1] != 0 || es[i+2] != 1 {
+			continue
+		}
+		nal := es[i+3:]
+		if end := bytes.Index(nal, []byte{0, 0, 1}); end >= 0 {
+			nal = bytes.TrimRight(nal[:end], "\x00")
+		}
+		if len(nal) == 0 {
+			continue
+		}
+		var isSps bool
+		switch a.videoType {
+		case kStreamTypeH264:
+			isSps = nal[0]&0x1F == kNalSpsH264
+		case kStreamTypeH265:
+			isSps = nal[0]>>1&0x3F == kNalSpsH265
+		}
+		if !isSps {
+			continue
+		}
+		if bytes.Equal(nal, a.lastSps) {
+			return
+		}
+		var format videoFormat_t
+		var err error
+		if a.videoType == kStreamTypeH264 {
+			format, err = parseH264Sps(nal)
+		} else {
+			format, err = parseH265Sps(nal)
+		}
+		if err == nil {
+			a.lastSps = slices.Clone(nal)
+			a.format = format
+			a.changed = true
+		}
+		return
+	}
+}
+
+func (a *tsAnalyser_t) measureBitrates(now time.Time) {
+	seconds := now.Sub(a.windowStart).Seconds()
+	a.bitrates = a.bitrates[:0]
+	total := 0
+	for pid, n := range a.counts {
+		a.bitrates = append(a.bitrates, PidBitrate_t{Pid: pid, Kbps: float64(n) * 8 / seconds / 1000})
+		total += n
+	}
+	slices.SortFunc(a.bitrates, func(x, y PidBitrate_t) int { return int(x.Pid) - int(y.Pid) })
+	a.totalKbps = float64(total) * 8 / seconds / 1000
+	clear(a.counts)
+	a.windowStart = now
+	a.changed = true
+}
+
+// Returns the frame rate from the PTS, or else from the SPS
+func (a *tsAnalyser_t) frameRate() float64 {
+	if len(a.ptsDeltas) >= kPtsDeltas/2 {
+		sorted := slices.Sorted(slices.Values(a.ptsDeltas))
+		return kPtsHz / float64(sorted[len(sorted)/2])
+	}
+	return a.format.frameRate
+}
+
+// Copies the results to d. Returns true if they have changed.
+func (a *tsAnalyser_t) update(d *LmData_t) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.changed {
+		return false
+	}
+	a.changed = false
+
+	d.Resolution, d.FrameRate, d.VideoProfile = kDash, kDash, kDash
+	if a.format.width > 0 {
+		d.Resolution = fmt.Sprintf("%vx%v", a.format.width, a.format.height)
+		d.VideoProfile = a.format.profile
+	}
+	if rate := a.frameRate(); rate > 0 {
+		d.FrameRate = fmt.Sprintf("%.2f", rate)
+	}
+	d.TsKbps = kDash
+	d.VideoKbps = kDash
+	if a.totalKbps > 0 {
+		d.TsKbps = fmt.Sprintf("%.0f", a.totalKbps)
+	}
+	d.ServiceInfo = a.service
+	d.Bitrates = slices.Clone(a.bitrates) // sent to the UI, so never shared
+	for _, b := range d.Bitrates {
+		if a.videoPid != 0 && b.Pid == a.videoPid {
+			d.VideoKbps = fmt.Sprintf("%.0f", b.Kbps)
+		}
+	}
+	return true
+}
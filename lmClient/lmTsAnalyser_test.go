@@ -0,0 +1,138 @@
+package lmClient
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+const (
+	kTestPmtPid   = 0x100
+	kTestVideoPid = 0x101
+	kTestAudioPid = 0x102
+)
+
+// Returns a TS packet for pid, padded with stuffing bytes
+func tsPacket(pid uint16, pusi bool, payload []byte) []byte {
+	pkt := make([]byte, kTsPacketSize)
+	pkt[0] = kTsSync
+	pkt[1] = byte(pid >> 8 & 0x1F)
+	if pusi {
+		pkt[1] |= 0x40
+	}
+	pkt[2] = byte(pid)
+	pkt[3] = 0x10 // payload only
+	n := copy(pkt[4:], payload)
+	for i := 4 + n; i < kTsPacketSize; i++ {
+		pkt[i] = 0xFF
+	}
+	return pkt
+}
+
+// Returns the PAT and the PMT for one program, with H.264 video and MPEG audio
+func patAndPmt(videoType byte) []byte {
+	pat := section([]byte{0x00, 0, 0, 0, 1, 0xC1, 0, 0}, 0, 1, 0xE0|kTestPmtPid>>8, kTestPmtPid&0xFF)
+	pmt := section([]byte{0x02, 0, 0, 0, 1, 0xC1, 0, 0, 0xE1, 0x01, 0xF0, 0},
+		videoType, 0xE0|kTestVideoPid>>8, kTestVideoPid&0xFF, 0xF0, 0,
+		0x03, 0xE0|kTestAudioPid>>8, kTestAudioPid&0xFF, 0xF0, 0)
+	ts := tsPacket(kPidPat, true, append([]byte{0}, pat...))
+	return append(ts, tsPacket(kTestPmtPid, true, append([]byte{0}, pmt...))...)
+}
+
+// Returns a video PES packet with its PTS and the start of its elementary stream
+func videoPes(pts int64, es []byte) []byte {
+	pes := []byte{0, 0, 1, 0xE0, 0, 0, 0x80, 0x80, 5,
+		byte(0x21 | pts>>29&0x0E), byte(pts >> 22), byte(pts>>14 | 1), byte(pts >> 7), byte(pts<<1 | 1)}
+	return tsPacket(kTestVideoPid, true, append(pes, es...))
+}
+
+func TestAnalyserPsi(t *testing.T) {
+	a := newTsAnalyser()
+	ts := patAndPmt(kStreamTypeH265)
+	// split across writes, and after some junk to resync on
+	a.Write([]byte{1, 2, 3})
+	a.Write(ts[:100])
+	a.Write(ts[100:])
+
+	if !a.pmtPids[kTestPmtPid] || a.program != 1 {
+		t.Errorf("PAT gave PMT PIDs %v and program %v", a.pmtPids, a.program)
+	}
+	if a.videoPid != kTestVideoPid || a.videoType != kStreamTypeH265 {
+		t.Errorf("video PID %#x type %#x, want %#x %#x", a.videoPid, a.videoType, kTestVideoPid, kStreamTypeH265)
+	}
+	if a.streams[kTestAudioPid] != 0x03 {
+		t.Errorf("audio type %#x, want 0x03", a.streams[kTestAudioPid])
+	}
+}
+
+func TestAnalyserVideo(t *testing.T) {
+	tests := []struct {
+		name      string
+		ptsStep   int64
+		frames    int
+		frameRate string
+	}{
+		{"25 fps from the PTS", kPtsHz / 25, kPtsDeltas, "25.00"},
+		{"50 fps from the PTS", kPtsHz / 50, kPtsDeltas, "50.00"},
+		{"too few frames, so from the SPS", kPtsHz / 50, 4, "25.00"},
+	}
+	sps := append([]byte{0, 0, 0, 1}, mustHex(t, kSps720p25H264)...)
+	for _, tt := range tests {
+		a := newTsAnalyser()
+		a.Write(patAndPmt(kStreamTypeH264))
+		pts := int64(1<<33 - 2*tt.ptsStep) // wraps
+		for range tt.frames {
+			a.Write(videoPes(pts, sps))
+			pts = (pts + tt.ptsStep) % (1 << 33)
+		}
+		a.Write(videoPes(pts, nil)) // ends the last PES
+
+		d := LmData_t{}
+		if !a.update(&d) {
+			t.Fatalf("%v: not updated", tt.name)
+		}
+		if d.Resolution != "1280x720" || d.VideoProfile != "High@3.1" || d.FrameRate != tt.frameRate {
+			t.Errorf("%v: got %v %v %v fps, want 1280x720 High@3.1 %v", tt.name, d.Resolution, d.VideoProfile, d.FrameRate, tt.frameRate)
+		}
+		if a.update(&d) {
+			t.Errorf("%v: updated again without a change", tt.name)
+		}
+	}
+}
+
+func TestMeasureBitrates(t *testing.T) {
+	a := newTsAnalyser()
+	start := time.Now()
+	a.windowStart = start
+	for range 100 {
+		a.packet(tsPacket(kTestVideoPid, false, nil))
+	}
+	for range 10 {
+		a.packet(tsPacket(kTestAudioPid, false, nil))
+	}
+	for range 40 {
+		a.packet(tsPacket(kPidNull, false, nil))
+	}
+	a.videoPid = kTestVideoPid
+	a.measureBitrates(start.Add(2 * time.Second))
+
+	kbps := func(packets int) float64 { return float64(packets) * kTsPacketSize * 8 / 2 / 1000 }
+	want := []PidBitrate_t{{kTestVideoPid, kbps(100)}, {kTestAudioPid, kbps(10)}, {kPidNull, kbps(40)}}
+	if len(a.bitrates) != len(want) {
+		t.Fatalf("bitrates %v, want %v", a.bitrates, want)
+	}
+	for i := range want {
+		if a.bitrates[i].Pid != want[i].Pid || math.Abs(a.bitrates[i].Kbps-want[i].Kbps) > 1e-9 {
+			t.Errorf("bitrate %v, want %v", a.bitrates[i], want[i])
+		}
+	}
+
+	d := LmData_t{}
+	a.update(&d)
+	if d.TsKbps != "113" || d.VideoKbps != "75" {
+		t.Errorf("TS %v kb/s, video %v kb/s, want 113 and 75", d.TsKbps, d.VideoKbps)
+	}
+	if len(a.counts) != 0 || !a.windowStart.Equal(start.Add(2*time.Second)) {
+		t.Errorf("window not restarted")
+	}
+}
@@ -0,0 +1,203 @@
+package lmClient
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+/***********************************************************************
+*
+*	DVB SERVICE INFORMATION
+*
+*	The SDT gives the service type and names, and the EIT the present
+*	event. Stations often put their callsign and locator in these, so
+*	both are searched for them.
+*
+*	see: ETSI EN 300 468
+*
+************************************************************************/
+
+const (
+	kPidSdt           = 0x11
+	kPidEit           = 0x12
+	kTableSdtActual   = 0x42
+	kTableEitActualPf = 0x4E // present and following
+	kDescService      = 0x48
+	kDescShortEvent   = 0x4D
+	kDescExtEvent     = 0x4E
+)
+
+type (
+	// Service information from the SDT and EIT
+	ServiceInfo_t struct {
+		ServiceType string // ie. "H.264 HD TV"
+		Provider    string
+		Name        string
+		EventName   string
+		EventText   string
+		Callsign    string // found in any of the above
+		Locator     string // Maidenhead
+	}
+)
+
+var (
+	callsignRegexp = regexp.MustCompile(`\b(?:[A-Z]{1,2}|[0-9][A-Z]|[A-Z][0-9])[0-9][A-Z]{1,4}\b`)
+	locatorRegexp  = regexp.MustCompile(`\b[A-R]{2}[0-9]{2}(?:[A-X]{2})?\b`)
+)
+
+var const_ServiceTypes = map[byte]string{
+	0x01: "TV",
+	0x02: "Radio",
+	0x03: "Teletext",
+	0x0A: "Radio",
+	0x0C: "Data",
+	0x11: "MPEG-2 HD TV",
+	0x16: "H.264 SD TV",
+	0x19: "H.264 HD TV",
+	0x1F: "HEVC TV",
+	0x20: "HEVC UHD TV",
+}
+
+// Service Description Table
+func (a *tsAnalyser_t) sdt(section []byte) {
+	if len(section) < 11+4 {
+		return
+	}
+	end := len(section) - 4 // CRC
+	for i := 11; i+5 <= end; {
+		serviceId := uint16(section[i])<<8 | uint16(section[i+1])
+		length := int(section[i+3]&0x0F)<<8 | int(section[i+4])
+		descriptors := section[i+5 : min(i+5+length, end)]
+		i += 5 + length
+		if a.program != 0 && serviceId != a.program {
+			continue
+		}
+		info := a.service
+		forEachDescriptor(descriptors, func(tag byte, d []byte) {
+			if tag != kDescService || len(d) < 2 {
+				return
+			}
+			info.ServiceType = const_ServiceTypes[d[0]]
+			if info.ServiceType == "" {
+				info.ServiceType = kDash
+			}
+			var name []byte
+			info.Provider, name = dvbString(d[1:])
+			info.Name, _ = dvbString(name)
+		})
+		a.setService(info)
+		return
+	}
+}
+
+// Event Information Table, for the present event only
+func (a *tsAnalyser_t) eit(section []byte) {
+	if len(section) < 14+4 || section[6] != 0 { // section 0 is the present event
+		return
+	}
+	serviceId := uint16(section[3])<<8 | uint16(section[4])
+	if a.program != 0 && serviceId != a.program {
+		return
+	}
+	end := len(section) - 4 // CRC
+	info := a.service
+	info.EventName, info.EventText = "", ""
+	if i := 14; i+12 <= end {
+		length := int(section[i+10]&0x0F)<<8 | int(section[i+11])
+		var extended strings.Builder
+		forEachDescriptor(section[i+12:min(i+12+length, end)], func(tag byte, d []byte) {
+			switch {
+			case tag == kDescShortEvent && len(d) >= 4:
+				text := d[3:] // after the language code
+				info.EventName, text = dvbString(text)
+				info.EventText, _ = dvbString(text)
+			case tag == kDescExtEvent && len(d) >= 5:
+				items := int(d[4])
+				if 5+items >= len(d) {
+					return
+				}
+				text, _ := dvbString(d[5+items:])
+				extended.WriteString(text)
+			}
+		})
+		if extended.Len() > 0 {
+			info.EventText = strings.TrimSpace(info.EventText + "\n" + extended.String())
+		}
+	}
+	a.setService(info)
+}
+
+// Keeps the service information, finding any callsign and locator
+func (a *tsAnalyser_t) setService(info ServiceInfo_t) {
+	info.Callsign, info.Locator = "", ""
+	for _, s := range []string{info.Name, info.Provider, info.EventName, info.EventText} {
+		s = strings.ToUpper(s)
+		if info.Callsign == "" {
+			info.Callsign = callsignRegexp.FindString(s)
+		}
+		if info.Locator == "" {
+			info.Locator = locatorRegexp.FindString(s)
+		}
+	}
+	if info != a.service {
+		a.service = info
+		a.changed = true
+	}
+}
+
+func forEachDescriptor(descriptors []byte, f func(tag byte, d []byte)) {
+	for i := 0; i+2 <= len(descriptors); {
+		length := int(descriptors[i+1])
+		if i+2+length > len(descriptors) {
+			return
+		}
+		f(descriptors[i], descriptors[i+2:i+2+length])
+		i += 2 + length
+	}
+}
+
+// Returns a length-prefixed DVB string, and what follows it
+func dvbString(b []byte) (string, []byte) {
+	if len(b) == 0 {
+		return "", nil
+	}
+	length := min(int(b[0]), len(b)-1)
+	return dvbText(b[1 : 1+length]), b[1+length:]
+}
+
+// Decodes DVB text, which is ISO 6937 unless it starts with a character table
+// code. UTF-8 is decoded as such and anything else as Latin-1, which is close
+// enough for names and callsigns.
+func dvbText(b []byte) string {
+	isUtf8 := false
+	if len(b) > 0 && b[0] < 0x20 {
+		switch {
+		case b[0] == 0x15:
+			isUtf8 = true
+			b = b[1:]
+		case b[0] == 0x10 && len(b) >= 3:
+			b = b[3:]
+		case b[0] == 0x1F && len(b) >= 2:
+			b = b[2:]
+		default:
+			b = b[1:]
+		}
+	}
+	var s strings.Builder
+	for len(b) > 0 {
+		r, size := rune(b[0]), 1
+		if isUtf8 {
+			r, size = utf8.DecodeRune(b)
+		}
+		b = b[size:]
+		switch {
+		case r == 0x8A:
+			s.WriteByte('\n')
+		case r < 0x20 || (r >= 0x80 && r < 0xA0): // control and emphasis codes
+		default:
+			s.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(s.String())
+}
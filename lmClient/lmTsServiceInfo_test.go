@@ -0,0 +1,72 @@
+package lmClient
+
+import "testing"
+
+// Returns a section with its length set, and a dummy CRC
+func section(header []byte, body ...byte) []byte {
+	s := append(append([]byte{}, header...), body...)
+	s = append(s, 0, 0, 0, 0)
+	length := len(s) - 3
+	s[1], s[2] = 0xB0|byte(length>>8), byte(length)
+	return s
+}
+
+func dvbBytes(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+func TestServiceInfo(t *testing.T) {
+	a := newTsAnalyser()
+	a.program = 1
+
+	service := append([]byte{kDescService, 0, 0x19}, dvbBytes("QO-100")...)
+	service = append(service, dvbBytes("\x15EA7KIR")...)
+	service[1] = byte(len(service) - 2)
+	sdt := section([]byte{kTableSdtActual, 0, 0, 0, 1, 0xC1, 0, 0, 0, 1, 0xFF},
+		append([]byte{0, 1, 0xFC, 0x80, byte(len(service))}, service...)...)
+	a.sdt(sdt)
+
+	event := append([]byte{kDescShortEvent, 0, 'e', 'n', 'g'}, dvbBytes("Test card")...)
+	event = append(event, dvbBytes("From IM76 \x86near\x87 Malaga")...)
+	event[1] = byte(len(event) - 2)
+	eit := section([]byte{kTableEitActualPf, 0, 0, 0, 1, 0xC1, 0, 0, 0, 1, 0, 1, 0, 0x4E},
+		append([]byte{0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0x80, byte(len(event))}, event...)...)
+	a.eit(eit)
+
+	want := ServiceInfo_t{
+		ServiceType: "H.264 HD TV",
+		Provider:    "QO-100",
+		Name:        "EA7KIR",
+		EventName:   "Test card",
+		EventText:   "From IM76 near Malaga",
+		Callsign:    "EA7KIR",
+		Locator:     "IM76",
+	}
+	if a.service != want {
+		t.Errorf("got %+v\nwant %+v", a.service, want)
+	}
+
+	// another service is ignored
+	a.program = 2
+	a.sdt(sdt)
+	if a.service != want {
+		t.Errorf("service 1 changed by service 2")
+	}
+}
+
+func TestDvbText(t *testing.T) {
+	tests := []struct {
+		b    []byte
+		want string
+	}{
+		{[]byte("M0ABC"), "M0ABC"},
+		{[]byte{0x05, 'E', 0xE9}, "Eé"},                    // ISO 8859-9
+		{[]byte{0x15, 'E', 0xC3, 0xA9}, "Eé"},              // UTF-8
+		{[]byte{0x10, 0x00, 0x01, 'a', 0x8A, 'b'}, "a\nb"}, // ISO 8859-1, CR/LF
+	}
+	for _, tt := range tests {
+		if got := dvbText(tt.b); got != tt.want {
+			t.Errorf("%q is %q, want %q", tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package lmClient
+
+import (
+	"errors"
+	"fmt"
+)
+
+/***********************************************************************
+*
+*	H.264 AND H.265 SEQUENCE PARAMETER SETS
+*
+*	Only the fields needed for the resolution, profile, level and, for
+*	H.264, the frame rate are decoded. See ITU-T H.264 7.3.2.1 and
+*	ITU-T H.265 7.3.2.2.
+*
+************************************************************************/
+
+const (
+	kNalSpsH264 = 7
+	kNalSpsH265 = 33
+)
+
+type (
+	videoFormat_t struct {
+		width, height int
+		profile       string // ie. "High@4.1"
+		frameRate     float64
+	}
+
+	bitReader_t struct {
+		data []byte
+		pos  int // in bits
+		err  error
+	}
+)
+
+var (
+	errShortSps = errors.New("SPS is too short")
+	errBadSps   = errors.New("SPS is invalid")
+)
+
+const kMaxRefFrameOffsets = 255 // num_ref_frames_in_pic_order_cnt_cycle
+
+func (r *bitReader_t) u(n int) uint32 {
+	var v uint32
+	for range n {
+		if r.pos >= 8*len(r.data) {
+			r.err = errShortSps
+			return 0
+		}
+		v = v<<1 | uint32(r.data[r.pos/8]>>(7-r.pos%8))&1
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader_t) flag() bool {
+	return r.u(1) == 1
+}
+
+// Exp-Golomb unsigned
+func (r *bitReader_t) ue() uint32 {
+	zeros := 0
+	for !r.flag() {
+		if r.err != nil || zeros > 31 {
+			r.err = errShortSps
+			return 0
+		}
+		zeros++
+	}
+	return 1<<zeros - 1 + r.u(zeros)
+}
+
+// Exp-Golomb signed
+func (r *bitReader_t) se() int32 {
+	v := r.ue()
+	if v&1 == 1 {
+		return int32(v+1) / 2
+	}
+	return -int32(v / 2)
+}
+
+// Returns a NAL unit without its emulation prevention bytes, ie. 00 00 03 is 00 00
+func unescapeNal(nal []byte) []byte {
+	out := make([]byte, 0, len(nal))
+	zeros := 0
+	for _, b := range nal {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+var h264Profiles = map[uint32]string{
+	66:  "Baseline",
+	77:  "Main",
+	88:  "Extended",
+	100: "High",
+	110: "High 10",
+	122: "High 4:2:2",
+	244: "High 4:4:4",
+}
+
+// Parses an H.264 SPS, starting with its NAL header
+func parseH264Sps(nal []byte) (videoFormat_t, error) {
+	var v videoFormat_t
+	r := bitReader_t{data: unescapeNal(nal)}
+	r.u(8) // NAL header
+	profileIdc := r.u(8)
+	r.u(8) // constraint flags
+	levelIdc := r.u(8)
+	r.ue() // seq_parameter_set_id
+
+	chromaFormatIdc := uint32(1)
+	switch profileIdc {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chromaFormatIdc = r.ue()
+		if chromaFormatIdc == 3 {
+			r.u(1) // separate_colour_plane_flag
+		}
+		r.ue()        // bit_depth_luma_minus8
+		r.ue()        // bit_depth_chroma_minus8
+		r.u(1)        // qpprime_y_zero_transform_bypass_flag
+		if r.flag() { // seq_scaling_matrix_present_flag
+			lists := 8
+			if chromaFormatIdc == 3 {
+				lists = 12
+			}
+			for i := range lists {
+				if r.flag() {
+					size := 16
+					if i >= 6 {
+						size = 64
+					}
+					skipScalingList(&r, size)
+				}
+			}
+		}
+	}
+	r.ue()          // log2_max_frame_num_minus4
+	switch r.ue() { // pic_order_cnt_type
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.u(1) // delta_pic_order_always_zero_flag
+		r.se() // offset_for_non_ref_pic
+		r.se() // offset_for_top_to_bottom_field
+		n := r.ue()
+		if n > kMaxRefFrameOffsets {
+			return v, errBadSps
+		}
+		for i := uint32(0); i < n && r.err == nil; i++ {
+			r.se() // offset_for_ref_frame
+		}
+	}
+	r.ue() // max_num_ref_frames
+	r.u(1) // gaps_in_frame_num_value_allowed_flag
+	widthMbs := r.ue() + 1
+	heightMapUnits := r.ue() + 1
+	frameMbsOnly := r.u(1)
+	if frameMbsOnly == 0 {
+		r.u(1) // mb_adaptive_frame_field_flag
+	}
+	r.u(1) // direct_8x8_inference_flag
+	var cropLeft, cropRight, cropTop, cropBottom uint32
+	if r.flag() {
+		cropLeft, cropRight, cropTop, cropBottom = r.ue(), r.ue(), r.ue(), r.ue()
+	}
+
+	cropUnitX, cropUnitY := uint32(1), 2-frameMbsOnly
+	switch chromaFormatIdc {
+	case 1:
+		cropUnitX, cropUnitY = 2, 2*(2-frameMbsOnly)
+	case 2:
+		cropUnitX = 2
+	}
+	if r.err != nil {
+		return v, r.err
+	}
+	width, err := cropped(uint64(widthMbs)*16, uint64(cropUnitX)*(uint64(cropLeft)+uint64(cropRight)))
+	if err != nil {
+		return v, err
+	}
+	height, err := cropped(uint64(2-frameMbsOnly)*uint64(heightMapUnits)*16, uint64(cropUnitY)*(uint64(cropTop)+uint64(cropBottom)))
+	if err != nil {
+		return v, err
+	}
+	v.width, v.height = width, height
+
+	if r.flag() { // vui_parameters_present_flag
+		if r.flag() { // aspect_ratio_info_present_flag
+			if r.u(8) == 255 { // Extended_SAR
+				r.u(32)
+			}
+		}
+		if r.flag() { // overscan_info_present_flag
+			r.u(1)
+		}
+		if r.flag() { // video_signal_type_present_flag
+			r.u(4)
+			if r.flag() { // colour_description_present_flag
+				r.u(24)
+			}
+		}
+		if r.flag() { // chroma_loc_info_present_flag
+			r.ue()
+			r.ue()
+		}
+		if r.flag() { // timing_info_present_flag
+			numUnitsInTick := r.u(32)
+			timeScale := r.u(32)
+			if numUnitsInTick > 0 {
+				v.frameRate = float64(timeScale) / float64(2*numUnitsInTick)
+			}
+		}
+	}
+	if r.err != nil {
+		return v, r.err
+	}
+
+	name, ok := h264Profiles[profileIdc]
+	if !ok {
+		name = fmt.Sprintf("Profile %v", profileIdc)
+	}
+	v.profile = fmt.Sprintf("%v@%.1f", name, float64(levelIdc)/10)
+	return v, nil
+}
+
+func skipScalingList(r *bitReader_t, size int) {
+	last, next := int32(8), int32(8)
+	for range size {
+		if r.err != nil {
+			return
+		}
+		if next != 0 {
+			next = (last + r.se() + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
+
+var h265Profiles = map[uint32]string{
+	1: "Main",
+	2: "Main 10",
+	3: "Main Still",
+	4: "RExt",
+}
+
+// Parses an H.265 SPS, starting with its NAL header
+func parseH265Sps(nal []byte) (videoFormat_t, error) {
+	var v videoFormat_t
+	r := bitReader_t{data: unescapeNal(nal)}
+	r.u(16) // NAL header
+	r.u(4)  // sps_video_parameter_set_id
+	maxSubLayersMinus1 := int(r.u(3))
+	r.u(1) // sps_temporal_id_nesting_flag
+
+	// profile_tier_level
+	r.u(3) // general_profile_space, general_tier_flag
+	profileIdc := r.u(5)
+	r.u(32) // general_profile_compatibility_flags
+	r.u(32) // progressive, interlaced, non packed, frame only and 44 reserved bits
+	r.u(16)
+	levelIdc := r.u(8)
+	profilePresent := make([]bool, maxSubLayersMinus1)
+	levelPresent := make([]bool, maxSubLayersMinus1)
+	for i := range maxSubLayersMinus1 {
+		profilePresent[i] = r.flag()
+		levelPresent[i] = r.flag()
+	}
+	if maxSubLayersMinus1 > 0 {
+		for range 8 - maxSubLayersMinus1 {
+			r.u(2)
+		}
+	}
+	for i := range maxSubLayersMinus1 {
+		if profilePresent[i] {
+			r.u(32)
+			r.u(32)
+			r.u(24)
+		}
+		if levelPresent[i] {
+			r.u(8)
+		}
+	}
+
+	r.ue() // sps_seq_parameter_set_id
+	chromaFormatIdc := r.ue()
+	if chromaFormatIdc == 3 {
+		r.u(1) // separate_colour_plane_flag
+	}
+	width := r.ue()
+	height := r.ue()
+	var cropX, cropY uint64
+	if r.flag() { // conformance_window_flag
+		subWidthC, subHeightC := uint32(1), uint32(1)
+		switch chromaFormatIdc {
+		case 1:
+			subWidthC, subHeightC = 2, 2
+		case 2:
+			subWidthC = 2
+		}
+		left, right, top, bottom := r.ue(), r.ue(), r.ue(), r.ue()
+		cropX, cropY = uint64(subWidthC)*(uint64(left)+uint64(right)), uint64(subHeightC)*(uint64(top)+uint64(bottom))
+	}
+	if r.err != nil {
+		return v, r.err
+	}
+	var err error
+	if v.width, err = cropped(uint64(width), cropX); err != nil {
+		return v, err
+	}
+	if v.height, err = cropped(uint64(height), cropY); err != nil {
+		return v, err
+	}
+
+	name, ok := h265Profiles[profileIdc]
+	if !ok {
+		name = fmt.Sprintf("Profile %v", profileIdc)
+	}
+	v.profile = fmt.Sprintf("%v@%.1f", name, float64(levelIdc)/30)
+	return v, nil
+}
+
+// Returns size less crop, which must leave some picture
+func cropped(size, crop uint64) (int, error) {
+	if crop >= size {
+		return 0, errBadSps
+	}
+	return int(size - crop), nil
+}
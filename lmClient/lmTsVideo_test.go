@@ -0,0 +1,140 @@
+package lmClient
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// Typical libx264 and x265 SPS NAL units, at DATV picture sizes
+const (
+	kSps720p25H264 = "6764001facd9405005bb011000000300100000030320f1831960"
+	kSps720p30H264 = "6764001facd9405005bb016a02020280000003008000001e478c18cb"
+	kSps720pH265   = "420101016000000300b00000030000030078a00280802d1659596b0a2412b2"
+	kSps1080pH265  = "420101016000000300900000030000030078a003c08010e59652b2"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Writes the bits of an SPS for the tests of corrupt values
+type bitWriter_t struct {
+	data []byte
+	bits int
+}
+
+func (w *bitWriter_t) u(n int, v uint64) {
+	for i := n - 1; i >= 0; i-- {
+		if w.bits%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte(v>>i&1) << (7 - w.bits%8)
+		w.bits++
+	}
+}
+
+func (w *bitWriter_t) ue(v uint64) {
+	n := 0
+	for (v+1)>>n > 1 {
+		n++
+	}
+	w.u(n, 0)
+	w.u(n+1, v+1)
+}
+
+// Returns a Baseline SPS for 1280x720, with pic_order_cnt_type 1 if refFrames
+// is not zero, and the crop in pairs of pixels
+func baselineSps(refFrames uint64, cropRight, cropBottom uint64) []byte {
+	var w bitWriter_t
+	w.u(8, 0x67)
+	w.u(8, 66) // Baseline
+	w.u(8, 0)
+	w.u(8, 31)
+	w.ue(0) // seq_parameter_set_id
+	w.ue(0) // log2_max_frame_num_minus4
+	if refFrames == 0 {
+		w.ue(2) // pic_order_cnt_type
+	} else {
+		w.ue(1)
+		w.u(1, 0)
+		w.ue(0)
+		w.ue(0)
+		w.ue(refFrames)
+		for range min(refFrames, 4) {
+			w.ue(0)
+		}
+	}
+	w.ue(1)   // max_num_ref_frames
+	w.u(1, 0) // gaps_in_frame_num_value_allowed_flag
+	w.ue(80 - 1)
+	w.ue(45 - 1)
+	w.u(1, 1) // frame_mbs_only_flag
+	w.u(1, 1) // direct_8x8_inference_flag
+	if cropRight == 0 && cropBottom == 0 {
+		w.u(1, 0)
+	} else {
+		w.u(1, 1)
+		w.ue(0)
+		w.ue(cropRight)
+		w.ue(0)
+		w.ue(cropBottom)
+	}
+	w.u(1, 0) // vui_parameters_present_flag
+	w.u(1, 1) // rbsp_stop_one_bit
+	return w.data
+}
+
+func TestParseSps(t *testing.T) {
+	tests := []struct {
+		name          string
+		sps           []byte
+		parse         func([]byte) (videoFormat_t, error)
+		width, height int
+		profile       string
+		frameRate     float64
+	}{
+		{"H.264 720p25", mustHex(t, kSps720p25H264), parseH264Sps, 1280, 720, "High@3.1", 25},
+		{"H.264 720p30", mustHex(t, kSps720p30H264), parseH264Sps, 1280, 720, "High@3.1", 30},
+		{"H.265 720p", mustHex(t, kSps720pH265), parseH265Sps, 1280, 720, "Main@4.0", 0},
+		{"H.265 1080p", mustHex(t, kSps1080pH265), parseH265Sps, 1920, 1080, "Main@4.0", 0},
+		{"H.264 cropped", baselineSps(0, 8, 4), parseH264Sps, 1280 - 16, 720 - 8, "Baseline@3.1", 0},
+		{"H.264 reference offsets", baselineSps(4, 0, 0), parseH264Sps, 1280, 720, "Baseline@3.1", 0},
+	}
+	for _, tt := range tests {
+		v, err := tt.parse(tt.sps)
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+			continue
+		}
+		if v.width != tt.width || v.height != tt.height || v.profile != tt.profile || v.frameRate != tt.frameRate {
+			t.Errorf("%v: got %+v, want %vx%v %v %v", tt.name, v, tt.width, tt.height, tt.profile, tt.frameRate)
+		}
+	}
+}
+
+func TestParseBadSps(t *testing.T) {
+	tests := []struct {
+		name  string
+		sps   []byte
+		parse func([]byte) (videoFormat_t, error)
+		want  error
+	}{
+		{"H.264 truncated", mustHex(t, kSps720p25H264)[:8], parseH264Sps, errShortSps},
+		{"H.265 truncated", mustHex(t, kSps1080pH265)[:12], parseH265Sps, errShortSps},
+		{"empty", nil, parseH264Sps, errShortSps},
+		{"too many reference offsets", baselineSps(1<<31, 0, 0), parseH264Sps, errBadSps},
+		{"crop wider than the picture", baselineSps(0, 640, 0), parseH264Sps, errBadSps},
+		{"crop taller than the picture", baselineSps(0, 0, 1<<30), parseH264Sps, errBadSps},
+	}
+	for _, tt := range tests {
+		if v, err := tt.parse(tt.sps); !errors.Is(err, tt.want) {
+			t.Errorf("%v: got %+v %v, want %v", tt.name, v, err, tt.want)
+		}
+	}
+}
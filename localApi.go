@@ -0,0 +1,100 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	config_ApiMaxEvents = 100 // most recent events kept
+)
+
+type (
+	apiEvent_t struct {
+		Id   int       `json:"id"`
+		Time time.Time `json:"time"`
+		Kind string    `json:"kind"`
+		Text string    `json:"text"`
+	}
+
+	// A small HTTP API on the local network
+	//
+	//	GET /events?since=id	returns the events after id, oldest first, as JSON
+	//	GET /logbook		returns the logbook as CSV
+	//	GET /logbook?format=adif	returns the logbook as ADIF
+	localApi_t struct {
+		mu     sync.Mutex
+		events []apiEvent_t
+		nextId int
+	}
+)
+
+// Starts the local API on addr, or returns nil if addr is empty
+func startLocalApi(addr string) *localApi_t {
+	if addr == "" {
+		return nil
+	}
+	api := &localApi_t{nextId: 1}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /events", api.handleEvents)
+	mux.HandleFunc("GET /logbook", handleLogbook)
+	go func() {
+		log.Printf("INFO local API on %v", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("ERROR local API stopped: %v", err)
+		}
+	}()
+	return api
+}
+
+// Adds an event. Does nothing if the API is not running.
+func (api *localApi_t) publish(kind, text string) {
+	if api == nil {
+		return
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.events = append(api.events, apiEvent_t{Id: api.nextId, Time: time.Now(), Kind: kind, Text: text})
+	api.nextId++
+	if len(api.events) > config_ApiMaxEvents {
+		api.events = api.events[len(api.events)-config_ApiMaxEvents:]
+	}
+}
+
+func (api *localApi_t) handleEvents(w http.ResponseWriter, r *http.Request) {
+	since, _ := strconv.Atoi(r.URL.Query().Get("since"))
+	api.mu.Lock()
+	events := []apiEvent_t{}
+	for _, e := range api.events {
+		if e.Id > since {
+			events = append(events, e)
+		}
+	}
+	api.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+func handleLogbook(w http.ResponseWriter, r *http.Request) {
+	if logbook == nil {
+		http.Error(w, "no logbook", http.StatusNotFound)
+		return
+	}
+	if r.URL.Query().Get("format") == "adif" {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Disposition", `attachment; filename="logbook.adi"`)
+		logbook.WriteAdif(w, time.Now())
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	logbook.WriteCsv(w)
+}
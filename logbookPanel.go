@@ -0,0 +1,123 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"os"
+	"path/filepath"
+	"q100receiver/rxLog"
+	"time"
+
+	"gioui.org/layout"
+	"github.com/ajstarks/giocanvas"
+)
+
+const (
+	config_LogbookFile  = "/home/pi/Q100/logbook.csv"
+	config_ExportFolder = "/home/pi/Q100/"
+)
+
+// Opens the logbook at path, or returns nil if it cannot be read
+func openLogbook(path string) *rxLog.Logbook {
+	if path == "" {
+		return nil
+	}
+	logbook, err := rxLog.Open(path)
+	if err != nil {
+		log.Printf("WARN logbook disabled: %v", err)
+		return nil
+	}
+	return logbook
+}
+
+// Writes the logbook to the export folder as CSV and ADIF, and returns the
+// file name without its extension
+func exportLogbook(logbook *rxLog.Logbook, now time.Time) (string, error) {
+	if logbook == nil {
+		return "", fmt.Errorf("no logbook")
+	}
+	name := filepath.Join(config_ExportFolder, "logbook_"+now.UTC().Format("20060102_1504"))
+	write := func(ext string, writeTo func(f *os.File) error) error {
+		f, err := os.Create(name + ext)
+		if err != nil {
+			return err
+		}
+		if err := writeTo(f); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	if err := write(".csv", func(f *os.File) error { return logbook.WriteCsv(f) }); err != nil {
+		return "", err
+	}
+	if err := write(".adi", func(f *os.File) error { return logbook.WriteAdif(f, now) }); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// Returns the stations logged, newest first, with an Export button
+func (ui *UI) q100_LogbookPanel(gtx C, width, height int) D {
+	return layout.Stack{Alignment: layout.NE}.Layout(gtx,
+		layout.Stacked(func(gtx C) D {
+			return ui.q100_LogbookCanvas(gtx, width, height)
+		}),
+		layout.Stacked(func(gtx C) D {
+			gtx.Constraints.Min.X = gtx.Dp(80)
+			return ui.q100_Button(gtx, &ui.export, "Export", false, q100color.buttonGrey)
+		}),
+	)
+}
+
+func (ui *UI) q100_LogbookCanvas(gtx C, width, height int) D {
+	canvas := giocanvas.Canvas{
+		Width:   float32(width),
+		Height:  float32(height),
+		Context: gtx,
+		Theme:   ui.th,
+	}
+	canvas.Background(q100color.gfxBgd)
+
+	columns := [...]float32{2, 22, 36, 46, 66, 76}
+	row := func(y float32, c [6]string, colour color.NRGBA) {
+		for i, s := range c {
+			canvas.Text(columns[i], y, kPanelTextSize, s, colour)
+		}
+	}
+	row(90, [6]string{"UTC", "MHz", "kS", "Callsign", "MER", "Duration"}, q100color.labelWhite)
+
+	y := 90 - float32(kPanelRowStep)
+	if ui.exported != "" {
+		canvas.Text(columns[0], 3, kPanelTextSize, ui.exported, q100color.labelWhite)
+	}
+	if logbook == nil {
+		canvas.Text(columns[0], y, kPanelTextSize, "No logbook", q100color.labelOrange)
+		return layout.Dimensions{Size: image.Point{X: width, Y: height}}
+	}
+	for i, e := range logbook.Entries() {
+		if i == kPanelMaxRows-1 { // leaving room for the export message
+			break
+		}
+		row(y, [6]string{
+			e.Start.Format("02 Jan 15:04"),
+			fmt.Sprintf("%.2f", e.FrequencyMHz),
+			fmt.Sprintf("%.0f", e.SymbolRateKS),
+			e.Callsign,
+			e.DbMer,
+			e.Duration.Round(time.Second).String(),
+		}, q100color.labelOrange)
+		y -= kPanelRowStep
+	}
+
+	return layout.Dimensions{
+		Size: image.Point{X: width, Y: height},
+	}
+}
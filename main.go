@@ -8,14 +8,18 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"image"
 	"image/color"
 	"log"
+	"math"
 	"os"
 	"os/exec"
 	"os/signal"
 	"q100receiver/lmClient"
 	"q100receiver/rxControl"
+	"q100receiver/rxLog"
+	"q100receiver/rxWatch"
 	"q100receiver/spClient"
 	"syscall"
 	"time"
@@ -25,6 +29,7 @@ import (
 	"gioui.org/io/system"
 	"gioui.org/layout"
 	"gioui.org/op"
+	"gioui.org/op/clip"
 	"gioui.org/op/paint"
 	"gioui.org/text"
 	"gioui.org/unit"
@@ -43,13 +48,22 @@ import (
 
 // local data
 var (
-	rxCmdChan  = make(chan rxControl.RxCmd_t)
-	rxData     = rxControl.RxData_t{}
-	rxDataChan = make(chan rxControl.RxData_t)
-	spData     = spClient.SpData_t{}
-	spDataChan = make(chan spClient.SpData_t, 1)
-	lmData     = lmClient.LmData_t{}
-	lmDataChan = make(chan lmClient.LmData_t)
+	rxCmdChan    = make(chan rxControl.RxCmd_t)
+	rxData       = rxControl.RxData_t{}
+	rxDataChan   <-chan rxControl.RxData_t
+	lmCmdChan    = make(chan lmClient.LmCmd_t, 1)
+	spData       = spClient.SpData_t{}
+	spDataChan   = make(chan spClient.SpData_t, 1)
+	spCmdChan    = make(chan spClient.SpCmd_t, 1)
+	lmData       = lmClient.LmData_t{}
+	lmDataChan   = make(chan lmClient.LmData_t)
+	localApi     *localApi_t
+	watchChan    = make(chan spClient.SpData_t, 1)
+	fineTuneChan = make(chan float64, 1) // offsets for the receiver to fine tune
+	statusChan   = make(chan lmClient.LmData_t, 1)
+	logbook      *rxLog.Logbook
+
+	watchEmptyTime time.Duration
 )
 
 func main() {
@@ -60,14 +74,66 @@ func main() {
 	log.Printf("INFO ----- q100receiver Opened -----")
 
 	var shutdown bool
+	var simFolder string
+	var spConfig spClient.SpConfig_t
+	var apiAddr string
+	var calibrateInterval time.Duration
+	var logbookFile string
 	flag.BoolVar(&shutdown, "shutdown", false, "close and poweroff")
+	flag.StringVar(&simFolder, "sim", "", "replay the status files and TS in this folder instead of running longmynd")
+	flag.StringVar(&spConfig.Source, "spectrum", "batc", "spectrum source: batc, replay, synth or sdr")
+	flag.StringVar(&spConfig.Address, "spaddr", "localhost:1234", "rtl_tcp address, or file:name of rtl_tcp samples, for the sdr spectrum")
+	flag.StringVar(&spConfig.File, "spfile", "", "spectrum capture or frames to replay")
+	flag.Float64Var(&spConfig.Speed, "spspeed", 1, "spectrum replay speed, ie. 1 for the original pace or 4 for 4 times faster")
+	flag.StringVar(&spConfig.Record, "sprecord", "", "capture every spectrum frame to this file")
+	flag.StringVar(&apiAddr, "api", "", "serve the local API on this address, ie. :8100")
+	flag.DurationVar(&calibrateInterval, "calibrate", 0, "calibrate the LNB on the beacon this often when not tuned, ie. 1h")
+	flag.DurationVar(&watchEmptyTime, "watchempty", 0, "how long a channel must be empty before a new carrier is alerted, ie. 10m")
+	flag.StringVar(&logbookFile, "logbook", config_LogbookFile, "log each station locked to this CSV file, or none if empty")
 	flag.Parse()
 	// fmt.Println("shudown: ", shutdown)
 
+	demodulator := lmClient.NewLongmynd()
+	if simFolder != "" {
+		log.Printf("INFO ----- simulating longmynd from %v -----", simFolder)
+		demodulator = lmClient.NewSimulator(simFolder)
+	}
+
+	logbook = openLogbook(logbookFile)
+	localApi = startLocalApi(apiAddr)
+
+	lmClient.CalibrateEvery(calibrateInterval)
+
 	ctx, cancel := context.WithCancel(context.Background())
 
-	go spClient.ReadSpectrumServer(ctx, spDataChan)
-	go rxControl.HandleCommands(ctx, rxCmdChan, rxDataChan, lmDataChan)
+	receiver := rxControl.NewReceiver(lmCmdChan)
+	rxDataChan = receiver.Subscribe()
+
+	go func() {
+		spectrumSource, err := spClient.NewSpectrumSource(spConfig)
+		if err != nil {
+			log.Fatalf("FATAL failed to open spectrum source: %v", err)
+		}
+		spClient.ReadSpectrum(ctx, spectrumSource, spCmdChan, spDataChan)
+	}()
+	go lmClient.ReadLonmyndStatus(ctx, demodulator, lmCmdChan, lmDataChan)
+	go receiver.HandleCommands(ctx, rxCmdChan)
+	go func() {
+		// not in the UI loop, as tuning may wait for lmClient, which may be waiting for the UI
+		for spData := range watchChan {
+			receiver.WatchSpectrum(&spData)
+		}
+	}()
+	go func() {
+		for offsetKHz := range fineTuneChan {
+			receiver.FineTune(offsetKHz)
+		}
+	}()
+	go func() {
+		for lmData := range statusChan {
+			receiver.UpdateStatus(lmData)
+		}
+	}()
 
 	go func() {
 		const WINDOW_MANAGER = 2 // 1 = X!!, 2 = Wayfire, = Labwc
@@ -122,7 +188,10 @@ func loop(w *app.Window) error {
 	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 
 	ui := UI{
-		th: material.NewTheme(),
+		th:        material.NewTheme(),
+		waterfall: newWaterfall(len(spClient.Xp), config_WaterfallDepth, config_WaterfallPalette),
+		view:      spectrumView_t{zoom: 1},
+		alerts:    watchAlerts_t{watcher: rxWatch.NewWatcher(rxControl.Channels(), watchEmptyTime)},
 	}
 
 	// Without this, the font sizes are inconsistent
@@ -146,9 +215,33 @@ func loop(w *app.Window) error {
 			// log.Printf("TEMP got rxData")
 			w.Invalidate()
 		case lmData = <-lmDataChan:
+			spClient.SetLnbLo(lmData.LnbLoKHz / 1000)
+			// blocks, so Stopped is never dropped; UpdateStatus doesn't wait on the UI
+			statusChan <- lmData
+			if ui.fineTune && isOffChannel(lmData) && time.Since(ui.lastFineTune) > config_FineTuneHoldoff {
+				ui.lastFineTune = time.Now()
+				select {
+				case fineTuneChan <- lmData.FreqOffsetKHz:
+				default:
+				}
+			}
+			if logbook != nil {
+				logbook.Update(lmData, time.Now())
+			}
 			// log.Printf("TEMP got lmData")
 			w.Invalidate()
 		case spData = <-spDataChan:
+			if spData.Error == "" { // else the last frame again, with the error shown on the spectrum
+				ui.waterfall.addRow(spData.Yp)
+				ui.alerts.update(time.Now())
+				if logbook != nil {
+					logbook.Update(lmData, time.Now()) // to end a lock without another status
+				}
+				select {
+				case watchChan <- spData:
+				default: // the receiver is busy, so skip a frame
+				}
+			}
 			w.Invalidate()
 		}
 
@@ -161,6 +254,43 @@ func loop(w *app.Window) error {
 			switch {
 			case ui.about.Clicked(gtx):
 				showAboutBox()
+			case ui.display.Clicked(gtx):
+				ui.displayMode = (ui.displayMode + 1) % len(displayModeNames)
+			case ui.average.Clicked(gtx):
+				spCmdChan <- spClient.CmdToggleAverage
+			case ui.peakHold.Clicked(gtx):
+				spCmdChan <- spClient.CmdTogglePeakHold
+			case ui.maxHold.Clicked(gtx):
+				spCmdChan <- spClient.CmdToggleMaxHold
+			case ui.info.Clicked(gtx):
+				if ui.displayMode == kDisplayInfo {
+					ui.displayMode = kDisplaySpectrum
+				} else {
+					ui.displayMode = kDisplayInfo
+				}
+			case ui.export.Clicked(gtx):
+				if name, err := exportLogbook(logbook, time.Now()); err != nil {
+					log.Printf("ERROR logbook not exported: %v", err)
+					ui.exported = "Not exported: " + err.Error()
+				} else {
+					log.Printf("INFO logbook exported to %v", name)
+					ui.exported = "Exported to " + name + ".csv and .adi"
+					localApi.publish("export", name)
+				}
+			case ui.report.Clicked(gtx):
+				if ui.signalReport.toggle(lmData, time.Now()) {
+					ui.signalReport.send(gtx)
+				}
+			case ui.watch.Clicked(gtx):
+				ui.alerts.toggle()
+			case ui.zoomIn.Clicked(gtx):
+				ui.view.zoomIn()
+			case ui.zoomOut.Clicked(gtx):
+				ui.view.zoomOut()
+			case ui.panLeft.Clicked(gtx):
+				ui.view.panBy(-1)
+			case ui.panRight.Clicked(gtx):
+				ui.view.panBy(1)
 			case ui.shutdown.Clicked(gtx):
 				interrupt <- syscall.SIGINT
 				// w.Perform(system.ActionClose)
@@ -180,6 +310,14 @@ func loop(w *app.Window) error {
 				rxCmdChan <- rxControl.CmdTune
 			case ui.stream.Clicked(gtx):
 				rxCmdChan <- rxControl.CmdStream
+			case ui.autoTune.Clicked(gtx):
+				rxCmdChan <- rxControl.CmdWatch
+			case ui.calibrate.Clicked(gtx):
+				rxCmdChan <- rxControl.CmdCalibrate
+			case ui.search.Clicked(gtx):
+				rxCmdChan <- rxControl.CmdSearch
+			case ui.fineTuneBtn.Clicked(gtx):
+				ui.fineTune = !ui.fineTune
 			}
 
 			paint.Fill(gtx.Ops, q100color.screenGrey)
@@ -192,16 +330,19 @@ func loop(w *app.Window) error {
 // custom color scheme
 var q100color = struct {
 	screenGrey                               color.NRGBA
-	labelWhite, labelOrange                  color.NRGBA
+	labelWhite, labelOrange, labelRed        color.NRGBA
 	buttonGrey, buttonGreen, buttonRed       color.NRGBA
 	gfxBgd, gfxGreen, gfxGraticule, gfxLabel color.NRGBA
 	gfxBeacon, gfxMarker                     color.NRGBA
+	gfxAverage, gfxPeakHold, gfxMaxHold      color.NRGBA
+	gfxAxisLabel, gfxSearch                  color.NRGBA
 }{
 	// see: https://pkg.go.dev/golang.org/x/image/colornames
 	// but maybe I should just create my own colors
 	screenGrey:   color.NRGBA{R: 16, G: 16, B: 16, A: 255}, // no LightBlack
 	labelWhite:   color.NRGBA(colornames.White),
-	labelOrange:  color.NRGBA(colornames.Darkorange),       // or Orange or Darkorange or Gold
+	labelOrange:  color.NRGBA(colornames.Darkorange), // or Orange or Darkorange or Gold
+	labelRed:     color.NRGBA(colornames.Red),
 	buttonGrey:   color.NRGBA{R: 32, G: 32, B: 32, A: 255}, // DarkGrey is too light
 	buttonGreen:  color.NRGBA(colornames.Green),
 	buttonRed:    color.NRGBA(colornames.Red),
@@ -211,18 +352,52 @@ var q100color = struct {
 	gfxMarker:    color.NRGBA{R: 20, G: 20, B: 20, A: 255},
 	gfxGraticule: color.NRGBA(colornames.Darkgray),
 	gfxLabel:     color.NRGBA{R: 32, G: 32, B: 32, A: 255}, // DarkGrey is too light
+	gfxAverage:   color.NRGBA(colornames.Yellow),
+	gfxPeakHold:  color.NRGBA(colornames.Cyan),
+	gfxMaxHold:   color.NRGBA(colornames.Magenta),
+	gfxAxisLabel: color.NRGBA{R: 96, G: 96, B: 96, A: 255},
+	gfxSearch:    color.NRGBA(colornames.Darkorange),
 }
 
 // define all buttons
 type UI struct {
-	about, shutdown              widget.Clickable
+	about, display, shutdown     widget.Clickable
+	average, peakHold, maxHold   widget.Clickable
+	watch                        widget.Clickable
+	info                         widget.Clickable
+	export, report               widget.Clickable
+	zoomIn, zoomOut              widget.Clickable
+	panLeft, panRight            widget.Clickable
 	decBand, incBand             widget.Clickable
 	decSymbolRate, incSymbolRate widget.Clickable
 	decFrequency, incFrequency   widget.Clickable
+	autoTune, calibrate          widget.Clickable
+	fineTuneBtn, search          widget.Clickable
 	tune, stream                 widget.Clickable
 	th                           *material.Theme
+	displayMode                  int
+	waterfall                    *waterfall_t
+	view                         spectrumView_t
+	probe                        signalProbe_t
+	alerts                       watchAlerts_t
+	fineTune                     bool
+	lastFineTune                 time.Time
+	exported                     string // the result of the last export
+	signalReport                 signalReport_t
 }
 
+// what the spectrum area shows, selected by the display button
+const (
+	kDisplaySpectrum = iota
+	kDisplayWaterfall
+	kDisplayBoth
+	kDisplayStreams
+	kDisplayInfo
+	kDisplayLogbook
+)
+
+var displayModeNames = [...]string{"Spectrum", "Waterfall", "Both", "Streams", "Info", "Log"}
+
 // makes the code more readable
 type (
 	C = layout.Context
@@ -240,6 +415,7 @@ type (
 [ [ label__  label__ ]   [ label__  label__ ]   [ label__  label__ ]  [ button ] ]
 [ [ label__  label__ ]   [ label__  label__ ]   [ label__  label__ ]             ]
 [ [ label__  label__ ]   [ label__  label__ ]   [ label__  label__ ]             ]
+[ [ label__  label__ ]   [ label__  label__ ]   [ label__  label__ ]             ]
 [ [ label__  label__ ]   [ label__  label__ ]   [ label__  label__ ]  [ button ] ]
 
 *********************************************************************************/
@@ -282,9 +458,10 @@ func (ui *UI) q100_Label(gtx C, label string, txtColor color.NRGBA) D {
 	return inset.Layout(gtx, lbl.Layout)
 }
 
-// Returns 1 row of 2 buttons and a label for About, Status and Shutdown
+// Returns 1 row of 7 buttons and a label for About, Status, Display, Traces, Watch and Shutdown
 func (ui *UI) q100_TopStatusRow(gtx C) D {
 	const btnWidth = 50
+	const traceBtnWidth = 40
 	inset := layout.Inset{
 		Top:    2,
 		Bottom: 2,
@@ -302,7 +479,43 @@ func (ui *UI) q100_TopStatusRow(gtx C) D {
 			})
 		}),
 		layout.Flexed(1, func(gtx C) D {
-			return ui.q100_Label(gtx, lmData.StatusMsg, q100color.labelOrange)
+			// the status opens the service information
+			return ui.info.Layout(gtx, func(gtx C) D {
+				if message, ok := ui.alerts.showing(time.Now()); ok {
+					return ui.q100_Label(gtx, message, q100color.labelRed)
+				}
+				return ui.q100_Label(gtx, lmData.StatusMsg, q100color.labelOrange)
+			})
+		}),
+		layout.Rigid(func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+				return ui.q100_Button(gtx, &ui.display, displayModeNames[ui.displayMode], false, q100color.buttonGrey)
+			})
+		}),
+		layout.Rigid(func(gtx C) D {
+			gtx.Constraints.Min.X = gtx.Dp(traceBtnWidth)
+			return ui.q100_Button(gtx, &ui.average, "Avg", spData.Average != nil, q100color.buttonGreen)
+		}),
+		layout.Rigid(func(gtx C) D {
+			gtx.Constraints.Min.X = gtx.Dp(traceBtnWidth)
+			return ui.q100_Button(gtx, &ui.peakHold, "Peak", spData.PeakHold != nil, q100color.buttonGreen)
+		}),
+		layout.Rigid(func(gtx C) D {
+			gtx.Constraints.Min.X = gtx.Dp(traceBtnWidth)
+			return ui.q100_Button(gtx, &ui.maxHold, "Max", spData.MaxHold != nil, q100color.buttonGreen)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+				return ui.q100_Button(gtx, &ui.watch, "Watch", ui.alerts.watching, q100color.buttonGreen)
+			})
+		}),
+		layout.Rigid(func(gtx C) D {
+			return inset.Layout(gtx, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+				return ui.q100_Button(gtx, &ui.report, "Report", ui.signalReport.showing(time.Now()), q100color.buttonGreen)
+			})
 		}),
 		layout.Rigid(func(gtx C) D {
 			return inset.Layout(gtx, func(gtx C) D {
@@ -363,68 +576,189 @@ func (ui *UI) q100_MainTuningRow(gtx C) D {
 		layout.Rigid(func(gtx C) D {
 			return ui.q100_Selector(gtx, &ui.decSymbolRate, &ui.incSymbolRate, rxData.CurSymbolRate, btnWidth, 50)
 		}),
+		layout.Rigid(func(gtx C) D {
+			gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+			return ui.q100_Button(gtx, &ui.search, "Any", rxData.CurIsSearching, q100color.buttonGreen)
+		}),
 		layout.Rigid(func(gtx C) D {
 			return ui.q100_Selector(gtx, &ui.decFrequency, &ui.incFrequency, rxData.CurFrequency, btnWidth, 100)
 		}),
+		layout.Rigid(func(gtx C) D {
+			gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+			return ui.q100_Button(gtx, &ui.autoTune, "Auto", rxData.CurIsWatched, q100color.buttonGreen)
+		}),
+		layout.Rigid(func(gtx C) D {
+			gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+			return ui.q100_Button(gtx, &ui.fineTuneBtn, "Fine", ui.fineTune, q100color.buttonGreen)
+		}),
+		layout.Rigid(func(gtx C) D {
+			gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+			return ui.q100_Button(gtx, &ui.calibrate, "Cal "+lmData.LnbDrift, lmData.Calibrating, q100color.buttonGreen)
+		}),
 	)
 }
 
-// Returns the Spectrum display
-//
-// see: github.com/ajstarks/giocanvas for docs
+// Returns the Spectrum display, the Waterfall, both, or the Streams, Info or Logbook panel,
+// unless a signal report is being shown
 func (ui *UI) q100_SpectrumDisplay(gtx C) D {
+	const width, height = 788, 250
+	const bothSpectrumHeight = 150
+
+	spectrumHeight, waterfallHeight, panelHeight := height, 0, 0
+	switch ui.displayMode {
+	case kDisplayWaterfall:
+		spectrumHeight, waterfallHeight = 0, height
+	case kDisplayBoth:
+		spectrumHeight, waterfallHeight = bothSpectrumHeight, height-bothSpectrumHeight
+	case kDisplayStreams, kDisplayInfo, kDisplayLogbook:
+		spectrumHeight, panelHeight = 0, height
+	}
+	isReport := ui.signalReport.showing(time.Now())
+	if isReport {
+		spectrumHeight, waterfallHeight, panelHeight = 0, 0, height
+	}
+
 	return layout.Flex{
 		Axis:    layout.Horizontal,
 		Spacing: layout.SpaceSides,
 	}.Layout(gtx,
-		layout.Rigid(
-			func(gtx layout.Context) layout.Dimensions {
-				canvas := giocanvas.Canvas{
-					Width:   float32(788), //gtx.Constraints.Max.X), //float32(width),  //float32(gtx.Constraints.Max.X),
-					Height:  float32(250), //float32(hieght), //float32(500),
-					Context: gtx,
-					Theme:   ui.th,
-				}
-				// fmt("  Canvas: %#v\n", canvas.Context.Constraints)
-
-				canvas.Background(q100color.gfxBgd)
-				// tuning marker
-				canvas.Rect(rxData.MarkerCentre, 50, rxData.MarkerWidth, 100, q100color.gfxMarker)
-				// polygon
-				canvas.Polygon(spClient.Xp, spData.Yp, q100color.gfxGreen)
-				// graticule
-				const fyBase float32 = 3
-				const fyInc float32 = 5.88235
-				fy := fyBase
-				for y := 0; y < 17; y++ {
-					switch y {
-					case 15:
-						canvas.Text(1, fy, 1.5, "15dB", q100color.gfxLabel)
-						canvas.HLine(5, fy, 94, 0.01, q100color.gfxGraticule)
-					case 10:
-						canvas.Text(1, fy, 1.5, "10dB", q100color.gfxLabel)
-						canvas.HLine(5, fy, 94, 0.01, q100color.gfxGraticule)
-					case 5:
-						canvas.Text(1, fy, 1.5, "5dB", q100color.gfxLabel)
-						canvas.HLine(5, fy, 94, 0.01, q100color.gfxGraticule)
-					default:
-						canvas.HLine(5, fy, 94, 0.005, q100color.gfxGraticule)
+		layout.Rigid(func(gtx C) D {
+			return layout.Flex{
+				Axis: layout.Vertical,
+			}.Layout(gtx,
+				layout.Rigid(func(gtx C) D {
+					if spectrumHeight == 0 {
+						return D{}
 					}
-					fy += fyInc
-				}
-				// beacon level
-				canvas.HLine(5, spData.BeaconLevel, 94, 0.03, q100color.gfxBeacon)
+					return layout.Stack{Alignment: layout.NE}.Layout(gtx,
+						layout.Stacked(func(gtx C) D {
+							return ui.q100_SpectrumCanvas(gtx, width, spectrumHeight)
+						}),
+						layout.Stacked(ui.q100_ZoomButtons),
+					)
+				}),
+				layout.Rigid(func(gtx C) D {
+					if waterfallHeight == 0 {
+						return D{}
+					}
+					return ui.waterfall.layout(gtx, image.Point{X: width, Y: waterfallHeight}, ui.view.left(rxData.MarkerCentre), ui.view.span())
+				}),
+				layout.Rigid(func(gtx C) D {
+					switch {
+					case panelHeight == 0:
+						return D{}
+					case isReport:
+						return ui.q100_ReportPanel(gtx, width, panelHeight)
+					case ui.displayMode == kDisplayInfo:
+						return ui.q100_InfoPanel(gtx, width, panelHeight)
+					case ui.displayMode == kDisplayLogbook:
+						return ui.q100_LogbookPanel(gtx, width, panelHeight)
+					}
+					return ui.q100_StreamsPanel(gtx, width, panelHeight)
+				}),
+			)
+		}),
+	)
+}
 
-				return layout.Dimensions{
-					Size: image.Point{X: int(canvas.Width), Y: int(canvas.Height)},
-				}
-			},
-		),
+// Returns the Spectrum
+//
+// see: github.com/ajstarks/giocanvas for docs
+func (ui *UI) q100_SpectrumCanvas(gtx C, width, height int) D {
+	canvas := giocanvas.Canvas{
+		Width:   float32(width),
+		Height:  float32(height),
+		Context: gtx,
+		Theme:   ui.th,
+	}
+	// fmt("  Canvas: %#v\n", canvas.Context.Constraints)
+
+	// nothing is drawn outside the canvas when zoomed
+	defer clip.Rect{Max: image.Pt(width, height)}.Push(gtx.Ops).Pop()
+
+	left := ui.view.left(rxData.MarkerCentre)
+	ui.probe.update(gtx, &ui.view, left, width)
+	xp := make([]float32, len(spClient.Xp))
+	for i, x := range spClient.Xp {
+		xp[i] = ui.view.toCanvas(x, left)
+	}
+
+	canvas.Background(q100color.gfxBgd)
+	// tuning marker
+	canvas.Rect(ui.view.toCanvas(rxData.MarkerCentre, left), 50, rxData.MarkerWidth*float32(ui.view.zoom), 100, q100color.gfxMarker)
+	// polygon
+	canvas.Polygon(xp, spData.Yp, q100color.gfxGreen)
+	// frequency being trialled by longmynd
+	if lmData.FrequencyMHz > 0 && !lmData.Locked {
+		canvas.VLine(ui.view.toCanvas(spClient.MHzToX(lmData.FrequencyMHz), left), 5, 88, 0.1, q100color.gfxSearch)
+	}
+	// traces
+	if spData.Average != nil {
+		canvas.Polyline(xp, spData.Average, 0.2, q100color.gfxAverage)
+	}
+	if spData.PeakHold != nil {
+		canvas.Polyline(xp, spData.PeakHold, 0.2, q100color.gfxPeakHold)
+	}
+	if spData.MaxHold != nil {
+		canvas.Polyline(xp, spData.MaxHold, 0.2, q100color.gfxMaxHold)
+	}
+	// graticule
+	const fyBase float32 = 3
+	const fyInc float32 = 5.88235
+	fy := fyBase
+	for y := 0; y < 17; y++ {
+		switch y {
+		case 15:
+			canvas.Text(1, fy, 1.5, "15dB", q100color.gfxLabel)
+			canvas.HLine(5, fy, 94, 0.01, q100color.gfxGraticule)
+		case 10:
+			canvas.Text(1, fy, 1.5, "10dB", q100color.gfxLabel)
+			canvas.HLine(5, fy, 94, 0.01, q100color.gfxGraticule)
+		case 5:
+			canvas.Text(1, fy, 1.5, "5dB", q100color.gfxLabel)
+			canvas.HLine(5, fy, 94, 0.01, q100color.gfxGraticule)
+		default:
+			canvas.HLine(5, fy, 94, 0.005, q100color.gfxGraticule)
+		}
+		fy += fyInc
+	}
+	// frequency and channel graticule
+	ui.view.drawGraticule(&canvas, left)
+	// beacon level
+	canvas.HLine(5, spData.BeaconLevel, 94, 0.03, q100color.gfxBeacon)
+	canvas.Text(6, 90, 1.5, fmt.Sprintf("Beacon %.1f dB   C/N %.1f dB   Noise %.1f dB", spData.BeaconDb, spData.BeaconCnDb, spData.NoiseFloorDb), q100color.gfxBeacon)
+	if spData.Error != "" {
+		canvas.Text(6, 80, 1.5, spData.Error, q100color.gfxBeacon)
+	}
+	// touched signal
+	ui.probe.draw(&canvas, &ui.view, left)
+
+	return layout.Dimensions{
+		Size: image.Point{X: int(canvas.Width), Y: int(canvas.Height)},
+	}
+}
+
+// Returns a row of buttons to zoom and pan the spectrum
+func (ui *UI) q100_ZoomButtons(gtx C) D {
+	const btnWidth = 30
+	button := func(btn *widget.Clickable, label string) layout.FlexChild {
+		return layout.Rigid(func(gtx C) D {
+			gtx.Constraints.Min.X = gtx.Dp(btnWidth)
+			return ui.q100_Button(gtx, btn, label, false, q100color.buttonGrey)
+		})
+	}
+	return layout.Flex{
+		Axis: layout.Horizontal,
+	}.Layout(gtx,
+		button(&ui.panLeft, "<"),
+		button(&ui.zoomOut, "-"),
+		button(&ui.zoomIn, "+"),
+		button(&ui.panRight, ">"),
 	)
 }
 
 // returns [ label__  label__ ]
-func (ui *UI) q100_LabelValue(gtx C, label, value string) D {
+func (ui *UI) q100_LabelValue(gtx C, label, value string, valueColor color.NRGBA) D {
 	const lblWidth = 105
 	const valWidth = 110
 	inset := layout.Inset{
@@ -451,29 +785,32 @@ func (ui *UI) q100_LabelValue(gtx C, label, value string) D {
 			return inset.Layout(gtx, func(gtx C) D {
 				gtx.Constraints.Min.X = gtx.Dp(valWidth)
 				gtx.Constraints.Max.X = gtx.Dp(valWidth)
-				return ui.q100_Label(gtx, value, q100color.labelOrange)
+				return ui.q100_Label(gtx, value, valueColor)
 			})
 		}),
 	)
 }
 
-// returns a column of 4 rows of [label__  label__]
-func (ui *UI) q100_Column4Rows(gtx C, name, value [4]string) D {
+// returns a column of 5 rows of [label__  label__]
+func (ui *UI) q100_Column5Rows(gtx C, name, value [5]string, valueColor [5]color.NRGBA) D {
 	return layout.Flex{
 		Axis: layout.Vertical,
 		// Spacing: layout.SpaceEvenly,
 	}.Layout(gtx,
 		layout.Rigid(func(gtx C) D {
-			return ui.q100_LabelValue(gtx, name[0], value[0])
+			return ui.q100_LabelValue(gtx, name[0], value[0], valueColor[0])
+		}),
+		layout.Rigid(func(gtx C) D {
+			return ui.q100_LabelValue(gtx, name[1], value[1], valueColor[1])
 		}),
 		layout.Rigid(func(gtx C) D {
-			return ui.q100_LabelValue(gtx, name[1], value[1])
+			return ui.q100_LabelValue(gtx, name[2], value[2], valueColor[2])
 		}),
 		layout.Rigid(func(gtx C) D {
-			return ui.q100_LabelValue(gtx, name[2], value[2])
+			return ui.q100_LabelValue(gtx, name[3], value[3], valueColor[3])
 		}),
 		layout.Rigid(func(gtx C) D {
-			return ui.q100_LabelValue(gtx, name[3], value[3])
+			return ui.q100_LabelValue(gtx, name[4], value[4], valueColor[4])
 		}),
 	)
 }
@@ -509,29 +846,36 @@ func (ui *UI) q100_Column2Buttons(gtx C) D {
 	)
 }
 
-// Returns a 3x4 matrix of status + 1 column with 2 buttons
-func (ui *UI) q100_3x4statusMatrixPlus2buttons(gtx C) D {
-	names1 := [4]string{"Frequency", "Symbol Rate", "Mode", "Constellation"}
-	values1 := [4]string{lmData.Frequency, lmData.SymbolRate, lmData.Mode, lmData.Constellation}
+// Returns a 3x5 matrix of status + 1 column with 2 buttons
+func (ui *UI) q100_3x5statusMatrixPlus2buttons(gtx C) D {
+	names1 := [5]string{"Frequency", "Symbol Rate", "Mode", "FEC", "PIDs"}
+	values1 := [5]string{lmData.Frequency, lmData.SymbolRate, lmData.Mode + " " + lmData.Constellation, lmData.Fec, lmData.PidPair1 + " " + lmData.PidPair2}
 
-	names2 := [4]string{"FEC", "Codecs", "dB MER", "dB Margin"}
-	values2 := [4]string{lmData.Fec, lmData.VideoCodec + " " + lmData.AudioCodec, lmData.DbMer, lmData.DbMargin}
+	names2 := [5]string{"dB MER", "dB Margin", "dBm Power", "MHz Offset", "Null Ratio %"}
+	values2 := [5]string{lmData.DbMer, lmData.DbMargin, lmData.DbmPower, lmData.FreqOffset, lmData.NullRatio}
 
-	names3 := [4]string{"dBm Power", "Null Ratio %", "Video PID", "Audio PID"}
-	values3 := [4]string{lmData.DbmPower, lmData.NullRatio, lmData.PidPair1, lmData.PidPair2}
+	names3 := [5]string{"Codecs", "Resolution", "Frame Rate", "Profile", "kb/s TS Video"}
+	values3 := [5]string{lmData.VideoCodec + " " + lmData.AudioCodec, lmData.Resolution, lmData.FrameRate, lmData.VideoProfile, lmData.TsKbps + " " + lmData.VideoKbps}
+
+	orange := q100color.labelOrange
+	colors := [5]color.NRGBA{orange, orange, orange, orange, orange}
+	colors2 := colors
+	if isOffChannel(lmData) {
+		colors2[3] = q100color.labelRed
+	}
 
 	return layout.Flex{
 		Axis: layout.Horizontal,
 		// Spacing: layout.SpaceEvenly,
 	}.Layout(gtx,
 		layout.Rigid(func(gtx C) D {
-			return ui.q100_Column4Rows(gtx, names1, values1)
+			return ui.q100_Column5Rows(gtx, names1, values1, colors)
 		}),
 		layout.Rigid(func(gtx C) D {
-			return ui.q100_Column4Rows(gtx, names2, values2)
+			return ui.q100_Column5Rows(gtx, names2, values2, colors2)
 		}),
 		layout.Rigid(func(gtx C) D {
-			return ui.q100_Column4Rows(gtx, names3, values3)
+			return ui.q100_Column5Rows(gtx, names3, values3, colors)
 		}),
 		layout.Rigid(func(gtx C) D {
 			return ui.q100_Column2Buttons(gtx)
@@ -553,8 +897,20 @@ func (ui *UI) layoutFlexes(gtx C) D {
 				layout.Rigid(ui.q100_TopStatusRow),
 				layout.Rigid(ui.q100_SpectrumDisplay),
 				layout.Rigid(ui.q100_MainTuningRow),
-				layout.Rigid(ui.q100_3x4statusMatrixPlus2buttons),
+				layout.Rigid(ui.q100_3x5statusMatrixPlus2buttons),
 			)
 		}),
 	)
 }
+
+const (
+	config_OffChannelFraction = 0.1              // of the symbol rate, before the offset is shown in red
+	config_FineTuneHoldoff    = 30 * time.Second // between fine tunes, as each one restarts longmynd
+)
+
+// Returns true if a locked signal is further from the requested frequency than
+// config_OffChannelFraction of its symbol rate
+func isOffChannel(lmData lmClient.LmData_t) bool {
+	return lmData.Locked && lmData.SymbolRateKS > 0 &&
+		math.Abs(lmData.FreqOffsetKHz) > config_OffChannelFraction*lmData.SymbolRateKS
+}
@@ -0,0 +1,129 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package rxControl
+
+import (
+	"log"
+	"maps"
+	"math"
+	"q100receiver/spClient"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+/*****************************************************************
+* AUTOMATIC TUNING TO CARRIERS ON WATCHED CHANNELS
+*****************************************************************/
+
+const (
+	config_AutoTuneAbsentFrames = 20 // consecutive frames without the carrier before untuning
+)
+
+type watchedChannel_t struct {
+	channel Channel_t
+	carrier spClient.CarrierCount_t
+	absent  int  // consecutive frames without a carrier
+	handled bool // tuned to this carrier already, so wait until it goes
+}
+
+// Watches or stops watching the channel of the current frequency
+func (r *Receiver) ToggleWatched() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	number := channelNumber(r.rxData.CurFrequency)
+	if _, ok := r.watched[number]; ok {
+		delete(r.watched, number)
+		log.Printf("INFO stopped watching channel %v", number)
+	} else {
+		mhz, err := frequencyMHz(r.rxData.CurFrequency)
+		if err != nil {
+			log.Printf("ERROR bad frequency %v", r.rxData.CurFrequency)
+			return
+		}
+		r.watched[number] = &watchedChannel_t{
+			channel: Channel_t{MHz: mhz, Number: number},
+			carrier: spClient.CarrierCount_t{MHz: mhz},
+		}
+		log.Printf("INFO watching channel %v", number)
+	}
+	r.updateRxData()
+	r.publish()
+}
+
+// Tunes to a carrier appearing on a watched channel, with a matching symbol rate,
+// and untunes when it has gone. Nothing is done while tuned by hand. Channels
+// are checked in order, so the lowest with a carrier is tuned.
+func (r *Receiver) WatchSpectrum(spData *spClient.SpData_t) {
+	r.lock()
+	defer r.unlockAndSend()
+	for _, number := range slices.Sorted(maps.Keys(r.watched)) {
+		w := r.watched[number]
+		signal, ok := w.carrier.Update(spData)
+		if ok {
+			w.absent = 0
+		} else {
+			w.absent++
+			w.handled = false
+		}
+
+		switch {
+		case r.autoTuned == w.channel.Number && w.absent >= config_AutoTuneAbsentFrames:
+			log.Printf("INFO carrier on channel %v has gone", w.channel.Number)
+			r.untune()
+			r.updateRxData()
+			r.publish()
+		case !r.isTuned && !w.handled && w.carrier.Present():
+			if r.selectChannel(w.channel.Number, signal.BandwidthMHz) {
+				log.Printf("INFO carrier on channel %v, tuning to %v at %v", w.channel.Number, r.rxData.CurFrequency, r.rxData.CurSymbolRate)
+				r.tune()
+				r.autoTuned = w.channel.Number
+				w.handled = true
+				r.updateRxData()
+				r.publish()
+			}
+		}
+	}
+}
+
+/***********************************************************
+	the following must be called with r.mu held
+***********************************************************/
+
+// Selects the band, frequency and symbol rate best matching a carrier of
+// bandwidthMHz on the channel. Returns false if no band has the channel.
+func (r *Receiver) selectChannel(number string, bandwidthMHz float64) bool {
+	bestBand, bestFrequency, bestSymbolRate := -1, -1, -1
+	bestError := math.Inf(1)
+	for b, band := range const_BAND_LIST {
+		frequencies := r.frequencies[band]
+		f := slices.IndexFunc(frequencies.list, func(s string) bool { return channelNumber(s) == number })
+		if f < 0 {
+			continue
+		}
+		for s, symbolRate := range r.symbolRates[band].list {
+			ks, _ := strconv.ParseFloat(symbolRate, 64)
+			e := math.Abs(math.Log(spClient.OccupiedMHz(ks) / bandwidthMHz))
+			if e < bestError {
+				bestBand, bestFrequency, bestSymbolRate, bestError = b, f, s, e
+			}
+		}
+	}
+	if bestBand < 0 {
+		return false
+	}
+	r.band.set(bestBand)
+	r.frequencies[r.band.value].set(bestFrequency)
+	r.symbolRates[r.band.value].set(bestSymbolRate)
+	r.updateRxData()
+	return true
+}
+
+// Returns the channel number of a frequency such as "10491.50 / 00"
+func channelNumber(frequency string) string {
+	_, number, _ := strings.Cut(frequency, " / ")
+	return number
+}
@@ -7,8 +7,14 @@ package rxControl
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"math"
 	"q100receiver/lmClient"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 const (
@@ -21,6 +27,15 @@ const (
 	config_rxVeryNarrowFrequency  = "10496.00 / 14"
 	config_streamUrl              = "rtmp://rtmp.batc.org.uk/live/" // or some youtube channel
 	config_streamKey              = "my-stream-key"
+
+	// longmynd -S, searched either side of the frequency as a ratio of the symbol
+	// rate, wider at lower rates so a carrier a few kHz off the channel still locks
+	config_rxBeaconHalfScan     = 0.9
+	config_rxWideHalfScan       = 0.9
+	config_rxNarrowHalfScan     = 1.2
+	config_rxVeryNarrowHalfScan = 1.5
+
+	kSymbolRateTolerance = 0.05 // of a listed symbol rate, for a locked symbol rate to match it
 )
 
 type (
@@ -32,141 +47,404 @@ type (
 		MarkerWidth    float32
 		CurIsTuned     bool
 		CurIsStreaming bool
+		CurIsWatched   bool // the channel of CurFrequency is watched
+		CurIsAutoTuned bool // tuned by WatchSpectrum
+		CurIsSearching bool // searching every symbol rate in the band
 	}
-)
 
-var (
-	lmCmd              = lmClient.LmCmd_t{}
-	lmCmdChan          = make(chan lmClient.LmCmd_t, 1)
-	rxData             RxData_t
-	rxDataChan         chan<- RxData_t
-	bandSelector       selector_t
-	symbolRateSelector selector_t
-	frequencySelector  selector_t
-
-	isTuned  = false
-	isOffset = false
+	// A Receiver holds the band, symbol rate and frequency selections and
+	// drives lmClient through lmCmdChan. All methods are safe for concurrent use.
+	//
+	//	Commands for lmClient are queued while mu is held and sent after it is
+	//	released, with sendMu keeping them in order, so Snapshot and the
+	//	subscribers never wait for lmClient.
+	Receiver struct {
+		mu          sync.Mutex
+		sendMu      sync.Mutex // held from before mu until the commands are sent
+		lmCmdChan   chan<- lmClient.LmCmd_t
+		lmCmd       lmClient.LmCmd_t
+		outbox      []lmClient.LmCmd_t // commands to send once mu is released
+		rxData      RxData_t
+		band        *selector_t
+		symbolRates map[string]*selector_t
+		frequencies map[string]*selector_t
+		halfScans   map[string]float64 // ratio of the symbol rate, by band name
+		isTuned     bool
+		subscribers []chan RxData_t
+		watched     map[string]*watchedChannel_t // by channel number
+		autoTuned   string                       // channel number, when tuned by WatchSpectrum
+		isSearching bool
+	}
 )
 
-func HandleCommands(ctx context.Context, rxCmdChan <-chan RxCmd_t, rxDataCh chan<- RxData_t, lmDataChan chan lmClient.LmData_t) {
-	rxDataChan = rxDataCh
-
-	bandSelector = newSelector(const_BAND_LIST, config_rxBand)
-
-	beaconSymbolRate = newSelector(const_BEACON_SYMBOLRATE_LIST, const_BEACON_SYMBOLRATE_LIST[0])
-	beaconFrequency = newSelector(const_BEACON_FREQUENCY_LIST, const_BEACON_FREQUENCY_LIST[0])
-
-	wideSymbolRate = newSelector(const_WIDE_SYMBOLRATE_LIST, config_rxWideSymbolrate)
-	wideFrequency = newSelector(const_WIDE_FREQUENCY_LIST, config_rxWideFrequency)
-
-	narrowSymbolRate = newSelector(const_NARROW_SYMBOLRATE_LIST, config_rxNarrowSymbolrate)
-	narrowFrequency = newSelector(const_NARROW_FREQUENCY_LIST, config_rxNarrowFrequency)
-
-	veryNarrowSymbolRate = newSelector(const_VERY_NARROW_SYMBOLRATE_LIST, config_rxNarrowSymbolrate)
-	veryNarrowFrequency = newSelector(const_VERY_NARROW_FREQUENCY_LIST, config_rxVeryNarrowFrequency)
-
-	switchBand()
-
-	go lmClient.ReadLonmyndStatus(ctx, lmCmdChan, lmDataChan)
+// Returns a Receiver set to the configured band, symbol rates and frequencies.
+//
+//	Commands for lmClient are sent to lmCmdChan, which is normally read by
+//	lmClient.ReadLonmyndStatus.
+func NewReceiver(lmCmdChan chan<- lmClient.LmCmd_t) *Receiver {
+	r := &Receiver{
+		lmCmdChan: lmCmdChan,
+		band:      newSelector(const_BAND_LIST, config_rxBand),
+		symbolRates: map[string]*selector_t{
+			const_BAND_LIST[0]: newSelector(const_BEACON_SYMBOLRATE_LIST, const_BEACON_SYMBOLRATE_LIST[0]),
+			const_BAND_LIST[1]: newSelector(const_WIDE_SYMBOLRATE_LIST, config_rxWideSymbolrate),
+			const_BAND_LIST[2]: newSelector(const_NARROW_SYMBOLRATE_LIST, config_rxNarrowSymbolrate),
+			const_BAND_LIST[3]: newSelector(const_VERY_NARROW_SYMBOLRATE_LIST, config_rxVeryNarrowSymbolRate),
+		},
+		frequencies: map[string]*selector_t{
+			const_BAND_LIST[0]: newSelector(const_BEACON_FREQUENCY_LIST, const_BEACON_FREQUENCY_LIST[0]),
+			const_BAND_LIST[1]: newSelector(const_WIDE_FREQUENCY_LIST, config_rxWideFrequency),
+			const_BAND_LIST[2]: newSelector(const_NARROW_FREQUENCY_LIST, config_rxNarrowFrequency),
+			const_BAND_LIST[3]: newSelector(const_VERY_NARROW_FREQUENCY_LIST, config_rxVeryNarrowFrequency),
+		},
+		halfScans: map[string]float64{
+			const_BAND_LIST[0]: config_rxBeaconHalfScan,
+			const_BAND_LIST[1]: config_rxWideHalfScan,
+			const_BAND_LIST[2]: config_rxNarrowHalfScan,
+			const_BAND_LIST[3]: config_rxVeryNarrowHalfScan,
+		},
+		watched: map[string]*watchedChannel_t{},
+	}
+	r.updateRxData()
+	return r
+}
 
+// Translates RxCmd_t values from the UI into Receiver method calls until ctx is cancelled.
+func (r *Receiver) HandleCommands(ctx context.Context, rxCmdChan <-chan RxCmd_t) {
 	for {
 		select {
 		case <-ctx.Done():
-			// if isTuned {
-			// 	lmCmd.Type = lmClient.CmdUnTune
-			// 	lmCmdChan <- lmCmd
-			// 	isTuned = false
-			// }
 			log.Printf("CANCEL ----- rxControl has cancelled")
 			return
 		case rxCmd := <-rxCmdChan:
 			switch rxCmd {
 			case CmdDecBand:
-				bandSelector.decBandSelector()
+				r.StepBand(-1)
 			case CmdIncBand:
-				bandSelector.incBandSelector()
+				r.StepBand(1)
 			case CmdDecSymbolRate:
-				symbolRateSelector.decSelector()
+				r.StepSymbolRate(-1)
 			case CmdIncSymbolRate:
-				symbolRateSelector.incSelector()
+				r.StepSymbolRate(1)
 			case CmdDecFrequency:
-				frequencySelector.decSelector()
+				r.StepFrequency(-1)
 			case CmdIncFrequency:
-				frequencySelector.incSelector()
+				r.StepFrequency(1)
 			case CmdTune:
-				setLongmynd()
+				r.ToggleTune()
 			case CmdStream:
-				toggleStreaming()
+				r.ToggleStreaming()
+			case CmdWatch:
+				r.ToggleWatched()
+			case CmdCalibrate:
+				r.ToggleCalibrate()
+			case CmdSearch:
+				r.ToggleSearch()
 			}
-			// default:
 		}
 	}
 }
 
-func setLongmynd() {
-	if isTuned {
-		lmCmd.Type = lmClient.CmdUnTune
-		lmCmdChan <- lmCmd
-		isTuned = false
+// Returns a channel which receives a copy of RxData_t after every change.
+//
+//	The channel holds only the latest RxData_t, so a slow reader never
+//	blocks the Receiver. The current state is available immediately.
+func (r *Receiver) Subscribe() <-chan RxData_t {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ch := make(chan RxData_t, 1)
+	ch <- r.rxData
+	r.subscribers = append(r.subscribers, ch)
+	return ch
+}
+
+// Stops sending to a channel returned by Subscribe
+func (r *Receiver) Unsubscribe(ch <-chan RxData_t) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, sub := range r.subscribers {
+		if sub == ch {
+			r.subscribers = append(r.subscribers[:i], r.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Returns a copy of the current RxData_t
+func (r *Receiver) Snapshot() RxData_t {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rxData
+}
+
+// Selects a band by name, ie. one of "Beacon", "Wide", "Narrow" or "V.Narrow"
+func (r *Receiver) SetBand(band string) error {
+	r.lock()
+	defer r.unlockAndSend()
+	index := slices.Index(const_BAND_LIST, band)
+	if index < 0 {
+		return fmt.Errorf("unknown band %q", band)
+	}
+	if r.band.set(index) {
+		r.somethingChanged()
+	}
+	return nil
+}
+
+// Moves the band selection up or down by delta
+func (r *Receiver) StepBand(delta int) {
+	r.lock()
+	defer r.unlockAndSend()
+	if r.band.step(delta) {
+		r.somethingChanged()
+	}
+}
+
+// Moves the symbol rate selection for the current band up or down by delta
+func (r *Receiver) StepSymbolRate(delta int) {
+	r.lock()
+	defer r.unlockAndSend()
+	if r.symbolRates[r.band.value].step(delta) {
+		r.somethingChanged()
+	}
+}
+
+// Moves the frequency selection for the current band up or down by delta
+func (r *Receiver) StepFrequency(delta int) {
+	r.lock()
+	defer r.unlockAndSend()
+	if r.frequencies[r.band.value].step(delta) {
+		r.somethingChanged()
+	}
+}
+
+// Tunes longmynd to the current frequency and symbol rate
+func (r *Receiver) Tune() {
+	r.lock()
+	defer r.unlockAndSend()
+	if !r.isTuned {
+		r.tune()
+		r.publish()
+	}
+}
+
+// Stops longmynd
+func (r *Receiver) Untune() {
+	r.lock()
+	defer r.unlockAndSend()
+	if r.isTuned {
+		r.untune()
+		r.publish()
+	}
+}
+
+// Tunes when untuned and untunes when tuned, as the TUNE button does
+func (r *Receiver) ToggleTune() {
+	r.lock()
+	defer r.unlockAndSend()
+	if r.isTuned {
+		r.untune()
 	} else {
-		lmCmd.Type = lmClient.CmdTune
-		lmCmd.FrequencyStr = rxData.CurFrequency
-		lmCmd.SymbolRateStr = rxData.CurSymbolRate
-		lmCmdChan <- lmCmd
-		isTuned = true
+		r.tune()
+	}
+	r.publish()
+}
+
+// Starts or stops searching every symbol rate in the band when tuning, rather
+// than only the selected one
+func (r *Receiver) ToggleSearch() {
+	r.lock()
+	defer r.unlockAndSend()
+	r.isSearching = !r.isSearching
+	r.somethingChanged()
+}
+
+// Follows the demodulator status, clearing the tuned state if it has stopped,
+// and selecting the symbol rate found by a search once longmynd has locked
+func (r *Receiver) UpdateStatus(lmData lmClient.LmData_t) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if lmData.Stopped && r.isTuned {
+		log.Printf("WARN demodulator has stopped, so no longer tuned")
+		r.clearTuned()
+		r.publish()
+		return
+	}
+	if !r.isTuned || !r.isSearching || !lmData.Locked || lmData.SymbolRateKS == 0 {
+		return
+	}
+	symbolRate := r.symbolRates[r.band.value]
+	for i, sr := range symbolRate.list {
+		ks, _ := strconv.ParseFloat(sr, 64)
+		if math.Abs(ks-lmData.SymbolRateKS) <= kSymbolRateTolerance*ks {
+			if symbolRate.set(i) {
+				log.Printf("INFO found %v kS", sr)
+				r.updateRxData()
+				r.publish()
+			}
+			return
+		}
 	}
-	rxData.CurIsTuned = isTuned
-	rxDataChan <- rxData
 }
 
-func toggleStreaming() {
-	if rxData.CurIsStreaming {
+// Tunes again with offsetKHz, measured by longmynd, added to the frequency,
+// so a drifting transmission stays centred. Does nothing if not tuned.
+func (r *Receiver) FineTune(offsetKHz float64) {
+	r.lock()
+	defer r.unlockAndSend()
+	if !r.isTuned {
+		return
+	}
+	if r.lmCmd.FrequencyKHz == 0 {
+		mhz, err := frequencyMHz(r.rxData.CurFrequency)
+		if err != nil {
+			log.Printf("ERROR bad frequency %v", r.rxData.CurFrequency)
+			return
+		}
+		r.lmCmd.FrequencyKHz = mhz * 1000
+	}
+	r.lmCmd.FrequencyKHz += offsetKHz
+	log.Printf("INFO fine tuning by %.0f kHz to %.3f MHz", offsetKHz, r.lmCmd.FrequencyKHz/1000)
+	r.outbox = append(r.outbox, r.lmCmd)
+}
+
+// Starts or cancels calibrating the LNB on the beacon, untuning first
+func (r *Receiver) ToggleCalibrate() {
+	r.lock()
+	defer r.unlockAndSend()
+	if r.isTuned {
+		r.untune()
+		r.publish()
+	}
+	r.outbox = append(r.outbox, lmClient.LmCmd_t{Type: lmClient.CmdToggleCalibrate})
+}
+
+// Starts or stops streaming to config_streamUrl
+func (r *Receiver) ToggleStreaming() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rxData.CurIsStreaming {
 		// log.Printf("TODO stop streaming to %v %v", config_streamUrl, config_streamKey)
-		rxData.CurIsStreaming = false
+		r.rxData.CurIsStreaming = false
 	} else {
 		// log.Printf("TODO start streaming to %v %v", config_streamUrl, config_streamKey)
-		rxData.CurIsStreaming = !true
+		r.rxData.CurIsStreaming = !true
 	}
-	rxDataChan <- rxData
+	r.publish()
 }
 
-type selector_t struct {
-	currIndex int
-	lastIndex int
-	list      []string
-	value     string
+// Locks for a method which may send commands to lmClient
+func (r *Receiver) lock() {
+	r.sendMu.Lock()
+	r.mu.Lock()
+}
+
+// Unlocks, then sends the commands queued while locked
+func (r *Receiver) unlockAndSend() {
+	cmds := r.outbox
+	r.outbox = nil
+	r.mu.Unlock()
+	defer r.sendMu.Unlock()
+	for _, cmd := range cmds {
+		r.lmCmdChan <- cmd
+	}
 }
 
-func (s *selector_t) incBandSelector() {
-	if s.currIndex < s.lastIndex {
-		s.currIndex++
-		s.value = s.list[s.currIndex]
-		switchBand()
+/***********************************************************
+	the following must be called with r.mu held, and tune
+	and untune only after r.lock
+***********************************************************/
+
+func (r *Receiver) tune() {
+	r.lmCmd.Type = lmClient.CmdTune
+	r.lmCmd.FrequencyStr = r.rxData.CurFrequency
+	r.lmCmd.FrequencyKHz = 0
+	r.lmCmd.HalfScanRatio = r.halfScans[r.band.value]
+	r.lmCmd.SymbolRateStr = r.rxData.CurSymbolRate
+	if r.isSearching {
+		// the selected symbol rate first
+		candidates := []string{r.rxData.CurSymbolRate}
+		for _, sr := range r.symbolRates[r.band.value].list {
+			if sr != r.rxData.CurSymbolRate {
+				candidates = append(candidates, sr)
+			}
+		}
+		r.lmCmd.SymbolRateStr = strings.Join(candidates, ",")
 	}
+	r.outbox = append(r.outbox, r.lmCmd)
+	r.isTuned = true
+	r.rxData.CurIsTuned = r.isTuned
+}
+
+func (r *Receiver) untune() {
+	r.lmCmd.Type = lmClient.CmdUnTune
+	r.outbox = append(r.outbox, r.lmCmd)
+	r.clearTuned()
+}
+
+// Clears the tuned state, without telling lmClient
+func (r *Receiver) clearTuned() {
+	r.isTuned = false
+	r.rxData.CurIsTuned = r.isTuned
+	r.autoTuned = ""
+	r.rxData.CurIsAutoTuned = false
 }
 
-func (s *selector_t) decBandSelector() {
-	if s.currIndex > 0 {
-		s.currIndex--
-		s.value = s.list[s.currIndex]
-		switchBand()
+func (r *Receiver) somethingChanged() {
+	if r.isTuned {
+		r.untune()
 	}
+	r.updateRxData()
+	r.publish()
 }
 
-func (s *selector_t) incSelector() {
-	if s.currIndex < s.lastIndex {
-		s.currIndex++
-		s.value = s.list[s.currIndex]
-		somethingChanged()
+func (r *Receiver) updateRxData() {
+	symbolRate := r.symbolRates[r.band.value]
+	frequency := r.frequencies[r.band.value]
+
+	r.rxData.CurBand = r.band.value
+	r.rxData.CurSymbolRate = symbolRate.value
+	r.rxData.CurFrequency = frequency.value
+
+	r.rxData.MarkerCentre = markerCentre(frequency.value)
+	r.rxData.MarkerWidth = const_symbolRateWidth[symbolRate.value]
+	r.rxData.CurIsTuned = r.isTuned
+	_, r.rxData.CurIsWatched = r.watched[channelNumber(frequency.value)]
+	r.rxData.CurIsAutoTuned = r.autoTuned != ""
+	r.rxData.CurIsSearching = r.isSearching
+}
+
+func (r *Receiver) publish() {
+	for _, ch := range r.subscribers {
+		select {
+		case <-ch: // discard the previous value if it hasn't been read
+		default:
+		}
+		ch <- r.rxData
 	}
 }
 
-func (s *selector_t) decSelector() {
-	if s.currIndex > 0 {
-		s.currIndex--
-		s.value = s.list[s.currIndex]
-		somethingChanged()
+type selector_t struct {
+	currIndex int
+	lastIndex int
+	list      []string
+	value     string
+}
+
+// Moves the selection by delta, stopping at either end of the list.
+// Returns true if the value has changed.
+func (s *selector_t) step(delta int) bool {
+	return s.set(min(max(s.currIndex+delta, 0), s.lastIndex))
+}
+
+// Selects list[index]. Returns true if the value has changed.
+func (s *selector_t) set(index int) bool {
+	if index == s.currIndex || index < 0 || index > s.lastIndex {
+		return false
 	}
+	s.currIndex = index
+	s.value = s.list[s.currIndex]
+	return true
 }
 
 var (
@@ -247,17 +525,29 @@ var (
 		"10499.00 / 26",
 		"10499.25 / 27",
 	}
-
-	beaconSymbolRate     selector_t
-	beaconFrequency      selector_t
-	wideSymbolRate       selector_t
-	narrowSymbolRate     selector_t
-	veryNarrowSymbolRate selector_t
-	wideFrequency        selector_t
-	narrowFrequency      selector_t
-	veryNarrowFrequency  selector_t
 )
 
+type Channel_t struct {
+	MHz    float64
+	Number string // ie. "09"
+}
+
+// Returns every channel in the frequency lists, in order of frequency
+func Channels() []Channel_t {
+	var channels []Channel_t
+	for _, list := range [][]string{const_BEACON_FREQUENCY_LIST, const_VERY_NARROW_FREQUENCY_LIST} {
+		for _, frequency := range list {
+			f, err := frequencyMHz(frequency)
+			if err != nil {
+				log.Printf("ERROR bad frequency %v", frequency)
+				continue
+			}
+			channels = append(channels, Channel_t{MHz: f, Number: channelNumber(frequency)})
+		}
+	}
+	return channels
+}
+
 type RxCmd_t int
 
 const (
@@ -269,6 +559,9 @@ const (
 	CmdIncFrequency  = 6
 	CmdTune          = 7
 	CmdStream        = 8
+	CmdWatch         = 9
+	CmdCalibrate     = 10
+	CmdSearch        = 11
 )
 
 func indexInList(list []string, with string) int { // TODO: add error check
@@ -280,9 +573,9 @@ func indexInList(list []string, with string) int { // TODO: add error check
 	return 0
 }
 
-func newSelector(values []string, with string) selector_t {
+func newSelector(values []string, with string) *selector_t {
 	index := indexInList(values, with)
-	st := selector_t{
+	st := &selector_t{
 		currIndex: index,
 		lastIndex: len(values) - 1,
 		list:      values,
@@ -290,37 +583,3 @@ func newSelector(values []string, with string) selector_t {
 	}
 	return st
 }
-
-func switchBand() { // TODO: should switch back to previosly use settings
-	switch bandSelector.value { // {Band.value {
-	case const_BAND_LIST[0]: // beacon
-		symbolRateSelector = beaconSymbolRate
-		frequencySelector = beaconFrequency
-	case const_BAND_LIST[1]: // wide
-		symbolRateSelector = wideSymbolRate
-		frequencySelector = wideFrequency
-	case const_BAND_LIST[2]: // narrow
-		symbolRateSelector = narrowSymbolRate
-		frequencySelector = narrowFrequency
-	case const_BAND_LIST[3]: // very narrow
-		symbolRateSelector = veryNarrowSymbolRate
-		frequencySelector = veryNarrowFrequency
-	}
-	somethingChanged()
-}
-
-func somethingChanged() {
-	lmCmd.Type = lmClient.CmdUnTune
-	lmCmdChan <- lmCmd
-	isTuned = false
-
-	rxData.CurBand = bandSelector.value
-	rxData.CurSymbolRate = symbolRateSelector.value
-	rxData.CurFrequency = frequencySelector.value
-
-	rxData.MarkerCentre = const_frequencyCentre[frequencySelector.value] / 9.18 // NOTE: 9.18 is a temporary kludge
-	rxData.MarkerWidth = const_symbolRateWidth[symbolRateSelector.value]
-	rxData.CurIsTuned = isTuned
-	rxData.CurIsStreaming = isOffset
-	rxDataChan <- rxData
-}
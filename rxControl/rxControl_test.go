@@ -0,0 +1,388 @@
+package rxControl
+
+import (
+	"fmt"
+	"q100receiver/lmClient"
+	"q100receiver/spClient"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLm stands in for lmClient.ReadLonmyndStatus and records every command
+type fakeLm struct {
+	ch   chan lmClient.LmCmd_t
+	mu   sync.Mutex
+	cmds []lmClient.LmCmd_t
+	done chan struct{}
+}
+
+func newFakeLm() *fakeLm {
+	f := &fakeLm{
+		ch:   make(chan lmClient.LmCmd_t, 1),
+		done: make(chan struct{}),
+	}
+	go func() {
+		for cmd := range f.ch {
+			f.mu.Lock()
+			f.cmds = append(f.cmds, cmd)
+			f.mu.Unlock()
+		}
+		close(f.done)
+	}()
+	return f
+}
+
+// closes the command channel and returns all commands received
+func (f *fakeLm) stop() []lmClient.LmCmd_t {
+	close(f.ch)
+	<-f.done
+	return f.cmds
+}
+
+func TestNewReceiver(t *testing.T) {
+	lm := newFakeLm()
+	r := NewReceiver(lm.ch)
+	got := r.Snapshot()
+	if got.CurBand != config_rxBand || got.CurSymbolRate != config_rxNarrowSymbolrate || got.CurFrequency != config_rxNarrowFrequency {
+		t.Errorf("got %v %v %v", got.CurBand, got.CurSymbolRate, got.CurFrequency)
+	}
+	if got.CurIsTuned {
+		t.Errorf("new Receiver is tuned")
+	}
+	if cmds := lm.stop(); len(cmds) != 0 {
+		t.Errorf("got %d commands, want none", len(cmds))
+	}
+}
+
+func TestBandSwitching(t *testing.T) {
+	lm := newFakeLm()
+	defer lm.stop()
+	r := NewReceiver(lm.ch)
+
+	if err := r.SetBand("V.Narrow"); err != nil {
+		t.Fatal(err)
+	}
+	got := r.Snapshot()
+	if got.CurBand != "V.Narrow" || got.CurSymbolRate != config_rxVeryNarrowSymbolRate || got.CurFrequency != config_rxVeryNarrowFrequency {
+		t.Errorf("got %v %v %v", got.CurBand, got.CurSymbolRate, got.CurFrequency)
+	}
+
+	// each band remembers its own selections
+	r.StepFrequency(1)
+	r.StepBand(-1)
+	if got := r.Snapshot(); got.CurBand != "Narrow" || got.CurFrequency != config_rxNarrowFrequency {
+		t.Errorf("got %v %v", got.CurBand, got.CurFrequency)
+	}
+	r.StepBand(1)
+	if got := r.Snapshot(); got.CurFrequency != "10496.25 / 15" {
+		t.Errorf("got %v, want 10496.25 / 15", got.CurFrequency)
+	}
+
+	// stepping stops at either end of the list
+	r.StepBand(-10)
+	if got := r.Snapshot(); got.CurBand != "Beacon" || got.CurFrequency != "10491.50 / 00" || got.CurSymbolRate != "1500" {
+		t.Errorf("got %v %v %v", got.CurBand, got.CurSymbolRate, got.CurFrequency)
+	}
+	r.StepBand(10)
+	if got := r.Snapshot(); got.CurBand != "V.Narrow" {
+		t.Errorf("got %v, want V.Narrow", got.CurBand)
+	}
+
+	if err := r.SetBand("Medium"); err == nil {
+		t.Errorf("SetBand accepted an unknown band")
+	}
+}
+
+func TestTuning(t *testing.T) {
+	lm := newFakeLm()
+	r := NewReceiver(lm.ch)
+
+	r.Tune()
+	r.Tune() // already tuned, so ignored
+	if !r.Snapshot().CurIsTuned {
+		t.Errorf("not tuned after Tune")
+	}
+	r.StepSymbolRate(1) // changing a selection untunes
+	if r.Snapshot().CurIsTuned {
+		t.Errorf("still tuned after StepSymbolRate")
+	}
+	r.Untune() // already untuned, so ignored
+	r.ToggleTune()
+	r.ToggleTune()
+
+	cmds := lm.stop()
+	want := []lmClient.LmCmd_t{
+		{Type: lmClient.CmdTune, FrequencyStr: "10499.25 / 27", SymbolRateStr: "333", HalfScanRatio: config_rxNarrowHalfScan},
+		{Type: lmClient.CmdUnTune, FrequencyStr: "10499.25 / 27", SymbolRateStr: "333", HalfScanRatio: config_rxNarrowHalfScan},
+		{Type: lmClient.CmdTune, FrequencyStr: "10499.25 / 27", SymbolRateStr: "500", HalfScanRatio: config_rxNarrowHalfScan},
+		{Type: lmClient.CmdUnTune, FrequencyStr: "10499.25 / 27", SymbolRateStr: "500", HalfScanRatio: config_rxNarrowHalfScan},
+	}
+	if len(cmds) != len(want) {
+		t.Fatalf("got %d commands %v, want %d", len(cmds), cmds, len(want))
+	}
+	for i := range want {
+		if cmds[i] != want[i] {
+			t.Errorf("command %d: got %+v, want %+v", i, cmds[i], want[i])
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	lm := newFakeLm()
+	defer lm.stop()
+	r := NewReceiver(lm.ch)
+
+	ch := r.Subscribe()
+	if got := <-ch; got.CurBand != config_rxBand {
+		t.Errorf("initial event: got %v, want %v", got.CurBand, config_rxBand)
+	}
+
+	// an unread event is replaced, never queued
+	r.StepBand(-1)
+	r.StepBand(-1)
+	if got := <-ch; got.CurBand != "Beacon" {
+		t.Errorf("got %v, want Beacon", got.CurBand)
+	}
+
+	r.Unsubscribe(ch)
+	r.StepBand(1)
+	select {
+	case got := <-ch:
+		t.Errorf("got %v after Unsubscribe", got.CurBand)
+	default:
+	}
+}
+
+func TestConcurrentCallers(t *testing.T) {
+	lm := newFakeLm()
+	defer lm.stop()
+	r := NewReceiver(lm.ch)
+	ch := r.Subscribe()
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			for range 50 {
+				r.StepBand(i%2*2 - 1)
+				r.StepFrequency(1)
+				r.ToggleTune()
+				r.Snapshot()
+			}
+		})
+	}
+	wg.Wait()
+
+	if got, want := <-ch, r.Snapshot(); got != want {
+		t.Errorf("last event %+v differs from Snapshot %+v", got, want)
+	}
+}
+
+func TestMarkerCentre(t *testing.T) {
+	lm := newFakeLm()
+	defer lm.stop()
+	r := NewReceiver(lm.ch)
+
+	r.SetBand("Beacon")
+	if got, want := r.Snapshot().MarkerCentre, spClient.MHzToX(10491.50); got != want {
+		t.Errorf("beacon marker: got %v, want %v", got, want)
+	}
+	for _, ch := range Channels() {
+		if got := markerCentre(fmt.Sprintf("%.2f / %s", ch.MHz, ch.Number)); got < 0 || got > 100 {
+			t.Errorf("channel %v marker %v is off the spectrum", ch.Number, got)
+		}
+	}
+}
+
+// Returns a frame with a 2 dB noise floor and a carrier 6 dB above it
+func frameWith(mhz, bandwidthMHz float64) *spClient.SpData_t {
+	const ypPerDb = 100.0 / 17
+	spData := &spClient.SpData_t{Yp: make([]float32, 918), NoiseFloorDb: 2}
+	for i := 1; i < 917; i++ {
+		f := spClient.XToMHz(100 * float32(i) / 918)
+		db := float32(2)
+		if f > mhz-bandwidthMHz/2 && f < mhz+bandwidthMHz/2 {
+			db = 8
+		}
+		spData.Yp[i] = 3 + db*ypPerDb
+	}
+	return spData
+}
+
+func TestAutoTune(t *testing.T) {
+	lm := newFakeLm()
+	r := NewReceiver(lm.ch)
+
+	r.ToggleWatched() // 10499.25 / 27
+	if !r.Snapshot().CurIsWatched {
+		t.Errorf("not watched after ToggleWatched")
+	}
+	r.SetBand("Wide") // 10494.75 / 09
+	r.ToggleWatched()
+	r.SetBand("Beacon")
+
+	empty := frameWith(0, 0)
+	wide := frameWith(10494.75, 1.35)
+	for range spClient.CarrierFrames {
+		r.WatchSpectrum(wide)
+	}
+	got := r.Snapshot()
+	if !got.CurIsTuned || !got.CurIsAutoTuned || got.CurBand != "Wide" || got.CurFrequency != "10494.75 / 09" || got.CurSymbolRate != "1000" {
+		t.Errorf("got %+v", got)
+	}
+
+	// untuned by hand, so not tuned again until the carrier has gone and come back
+	r.Untune()
+	for range spClient.CarrierFrames {
+		r.WatchSpectrum(wide)
+	}
+	if r.Snapshot().CurIsTuned {
+		t.Errorf("tuned again to the same carrier")
+	}
+	r.WatchSpectrum(empty)
+	for range spClient.CarrierFrames {
+		r.WatchSpectrum(wide)
+	}
+	for range config_AutoTuneAbsentFrames {
+		r.WatchSpectrum(empty)
+	}
+	if got := r.Snapshot(); got.CurIsTuned || got.CurIsAutoTuned {
+		t.Errorf("still tuned after the carrier has gone")
+	}
+
+	cmds := lm.stop()
+	want := []int{lmClient.CmdTune, lmClient.CmdUnTune, lmClient.CmdTune, lmClient.CmdUnTune}
+	if len(cmds) != len(want) {
+		t.Fatalf("got %d commands %v, want %d", len(cmds), cmds, len(want))
+	}
+	for i := range want {
+		if cmds[i].Type != want[i] {
+			t.Errorf("command %d: got %+v", i, cmds[i])
+		}
+	}
+}
+
+func TestAutoTuneLowestChannel(t *testing.T) {
+	lm := newFakeLm()
+	defer lm.stop()
+
+	// carriers on both watched channels, so the lowest is tuned every time
+	both := frameWith(10494.75, 1.35)
+	for i, y := range frameWith(10499.25, 0.45).Yp {
+		both.Yp[i] = max(both.Yp[i], y)
+	}
+	for range 10 {
+		r := NewReceiver(lm.ch)
+		r.ToggleWatched() // 10499.25 / 27
+		r.SetBand("Wide") // 10494.75 / 09
+		r.ToggleWatched()
+		r.SetBand("Beacon")
+		for range spClient.CarrierFrames {
+			r.WatchSpectrum(both)
+		}
+		if got := r.Snapshot().CurFrequency; got != "10494.75 / 09" {
+			t.Fatalf("tuned to %v, want channel 09", got)
+		}
+	}
+}
+
+func TestFineTune(t *testing.T) {
+	lm := newFakeLm()
+	r := NewReceiver(lm.ch)
+
+	r.FineTune(10) // not tuned, so ignored
+	r.Tune()
+	r.FineTune(-20)
+	r.FineTune(5)
+	r.Untune()
+	r.Tune() // the selected frequency again
+
+	cmds := lm.stop()
+	want := []float64{0, 10499250 - 20, 10499250 - 15, 0}
+	var got []float64
+	for _, cmd := range cmds {
+		if cmd.Type == lmClient.CmdTune {
+			got = append(got, cmd.FrequencyKHz)
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSymbolRateSearch(t *testing.T) {
+	lm := newFakeLm()
+	r := NewReceiver(lm.ch) // Narrow at 333
+
+	r.ToggleSearch()
+	r.Tune()
+	r.UpdateStatus(lmClient.LmData_t{Locked: false, SymbolRateKS: 250}) // still searching
+	if got := r.Snapshot().CurSymbolRate; got != "333" {
+		t.Errorf("got %v before lock, want 333", got)
+	}
+	r.UpdateStatus(lmClient.LmData_t{Locked: true, SymbolRateKS: 249.9})
+	if got := r.Snapshot(); got.CurSymbolRate != "250" || !got.CurIsTuned || !got.CurIsSearching {
+		t.Errorf("got %+v after lock", got)
+	}
+
+	cmds := lm.stop()
+	if len(cmds) != 1 || cmds[0].SymbolRateStr != "333,250,500" {
+		t.Errorf("got %+v, want one CmdTune searching 333,250,500", cmds)
+	}
+}
+
+func TestSnapshotWhileLmBusy(t *testing.T) {
+	lmCmdChan := make(chan lmClient.LmCmd_t) // nobody reading, as when lmClient is busy
+	r := NewReceiver(lmCmdChan)
+
+	go r.Tune() // blocks sending the tune
+	for !r.Snapshot().CurIsTuned {
+		time.Sleep(time.Millisecond)
+	}
+	go r.Untune() // waits for the tune to be sent
+
+	done := make(chan RxData_t)
+	go func() { done <- r.Snapshot() }()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Snapshot blocked while lmClient was busy")
+	}
+
+	// the commands are sent in order once lmClient reads them
+	if cmd := <-lmCmdChan; cmd.Type != lmClient.CmdTune {
+		t.Errorf("first command %+v, want a tune", cmd)
+	}
+	if cmd := <-lmCmdChan; cmd.Type != lmClient.CmdUnTune {
+		t.Errorf("second command %+v, want an untune", cmd)
+	}
+}
+
+func TestDemodulatorStopped(t *testing.T) {
+	lm := newFakeLm()
+	r := NewReceiver(lm.ch)
+	rxDataChan := r.Subscribe()
+
+	r.UpdateStatus(lmClient.LmData_t{Stopped: true}) // not tuned, so ignored
+	r.Tune()
+	<-rxDataChan
+	r.UpdateStatus(lmClient.LmData_t{Stopped: true})
+	select {
+	case got := <-rxDataChan:
+		if got.CurIsTuned || got.CurIsAutoTuned {
+			t.Errorf("got %+v, want not tuned", got)
+		}
+	default:
+		t.Errorf("not published")
+	}
+	if r.Snapshot().CurIsTuned {
+		t.Errorf("still tuned")
+	}
+	r.Tune() // tunes again, rather than untuning
+
+	cmds := lm.stop()
+	if len(cmds) != 2 || cmds[0].Type != lmClient.CmdTune || cmds[1].Type != lmClient.CmdTune {
+		t.Errorf("got %+v, want two CmdTune", cmds)
+	}
+}
@@ -1,42 +1,34 @@
 package rxControl
 
+import (
+	"log"
+	"q100receiver/spClient"
+	"strconv"
+	"strings"
+)
+
 /*****************************************************************
 * SPECTRUM MARKERS FOR RECEIVING
 *****************************************************************/
 
-var (
-	// TODO: calculatee a mathematical values
-	const_frequencyCentre = map[string]float32{
-		"10491.50 / 00": 103,
-		"10492.75 / 01": 230,
-		"10493.00 / 02": 256,
-		"10493.25 / 03": 281,
-		"10493.50 / 04": 307,
-		"10493.75 / 05": 332,
-		"10494.00 / 06": 358,
-		"10494.25 / 07": 383,
-		"10494.50 / 08": 409,
-		"10494.75 / 09": 434,
-		"10495.00 / 10": 460,
-		"10495.25 / 11": 485,
-		"10495.50 / 12": 511,
-		"10495.75 / 13": 536,
-		"10496.00 / 14": 562,
-		"10496.25 / 15": 588,
-		"10496.50 / 16": 613,
-		"10496.75 / 17": 639,
-		"10497.00 / 18": 664,
-		"10497.25 / 19": 690,
-		"10497.50 / 20": 715,
-		"10497.75 / 21": 741,
-		"10490.00 / 22": 767,
-		"10498.25 / 23": 792,
-		"10498.50 / 24": 818,
-		"10498.75 / 25": 843,
-		"10499.00 / 26": 869,
-		"10499.25 / 27": 894,
+// Returns the x coordinate of a frequency such as "10491.50 / 00",
+// using the same mapping as the spectrum graticule
+func markerCentre(frequency string) float32 {
+	f, err := frequencyMHz(frequency)
+	if err != nil {
+		log.Printf("ERROR bad frequency %v", frequency)
+		return 0
 	}
+	return spClient.MHzToX(f)
+}
+
+// Returns the MHz of a frequency such as "10491.50 / 00"
+func frequencyMHz(frequency string) (float64, error) {
+	mhz, _, _ := strings.Cut(frequency, " / ")
+	return strconv.ParseFloat(mhz, 64)
+}
 
+var (
 	// TODO: calculatee a mathematical values
 	const_symbolRateWidth = map[string]float32{
 		"2000": 20,
@@ -0,0 +1,105 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package rxLog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+/***********************************************************************
+*
+*	ADIF 3 EXPORT
+*
+*	Each entry with a callsign is a received-only (SWL) record via
+*	QO-100, with the 13cm uplink as the frequency and the 3cm downlink
+*	that was received as the RX frequency.
+*
+*	see: https://adif.org/314/ADIF_314.htm
+*
+************************************************************************/
+
+const (
+	kAdifVersion     = "3.1.4"
+	kProgramId       = "q100receiver"
+	kUplinkOffsetMHz = 8089.50 // QO-100 downlink less uplink
+)
+
+// Writes the entries with a callsign to w as ADIF, oldest first
+func (l *Logbook) WriteAdif(w io.Writer, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "Received by %v\n", kProgramId)
+	adifField(bw, "ADIF_VER", kAdifVersion)
+	adifField(bw, "PROGRAMID", kProgramId)
+	adifField(bw, "CREATED_TIMESTAMP", now.UTC().Format("20060102 150405"))
+	bw.WriteString("<EOH>\n")
+	for _, e := range l.entries {
+		if e.Callsign == "" {
+			continue
+		}
+		e.writeAdif(bw)
+	}
+	return bw.Flush()
+}
+
+func (e *Entry_t) writeAdif(w *bufio.Writer) {
+	end := e.Start.Add(e.Duration)
+	adifField(w, "CALL", strings.ToUpper(e.Callsign))
+	adifField(w, "QSO_DATE", e.Start.Format("20060102"))
+	adifField(w, "TIME_ON", e.Start.Format("150405"))
+	adifField(w, "QSO_DATE_OFF", end.Format("20060102"))
+	adifField(w, "TIME_OFF", end.Format("150405"))
+	adifField(w, "MODE", "DATV")
+	adifField(w, "BAND", "13cm")
+	adifField(w, "FREQ", fmt.Sprintf("%.4f", e.FrequencyMHz-kUplinkOffsetMHz))
+	adifField(w, "BAND_RX", "3cm")
+	adifField(w, "FREQ_RX", fmt.Sprintf("%.4f", e.FrequencyMHz))
+	adifField(w, "PROP_MODE", "SAT")
+	adifField(w, "SAT_NAME", "QO-100")
+	adifField(w, "SWL", "Y")
+	adifField(w, "COMMENT", e.adifComment())
+	w.WriteString("<EOR>\n")
+}
+
+// Returns the reception report, ie. "MER 9.5 dB margin 3.0 dB, 333 kS DVB-S2 QPSK 2/3"
+func (e *Entry_t) adifComment() string {
+	var report []string
+	if e.DbMer != "" {
+		report = append(report, "MER "+e.DbMer+" dB")
+	}
+	if e.DbMargin != "" {
+		report = append(report, "margin "+e.DbMargin+" dB")
+	}
+	signal := []string{fmt.Sprintf("%.0f kS", e.SymbolRateKS)}
+	for _, s := range []string{e.Mode, e.Constellation, e.Fec} {
+		if s != "" {
+			signal = append(signal, s)
+		}
+	}
+	if len(report) == 0 {
+		return strings.Join(signal, " ")
+	}
+	return strings.Join(report, " ") + ", " + strings.Join(signal, " ")
+}
+
+// Writes a field, keeping only printable ASCII as ADIF strings require
+func adifField(w *bufio.Writer, name, value string) {
+	value = strings.Map(func(r rune) rune {
+		if r < ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, value)
+	if value == "" {
+		return
+	}
+	fmt.Fprintf(w, "<%v:%v>%v ", name, len(value), value)
+}
@@ -0,0 +1,270 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package rxLog
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"q100receiver/lmClient"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	config_MinLockTime = 5 * time.Second  // shorter locks are not logged
+	config_UnlockTime  = 10 * time.Second // a relock on the same frequency within this continues the entry
+	config_LogBeacon   = false
+	kBeaconMHz         = 10491.50
+	kSameStationMHz    = 0.25
+	kDash              = "-"
+)
+
+var csvHeader = []string{
+	"Start UTC", "Duration s", "Frequency MHz", "Symbol Rate kS", "Mode", "Constellation", "FEC",
+	"MER dB", "Margin dB", "Provider", "Service", "Callsign",
+}
+
+type (
+	// A lock on a station, with the best MER and its margin during the lock
+	Entry_t struct {
+		Start         time.Time // UTC
+		Duration      time.Duration
+		FrequencyMHz  float64
+		SymbolRateKS  float64
+		Mode          string
+		Constellation string
+		Fec           string
+		DbMer         string
+		DbMargin      string
+		Provider      string
+		Service       string
+		Callsign      string // from the SDT or EIT, or else the service name
+	}
+
+	// A Logbook adds an entry for each lock, and keeps them in a CSV file.
+	// Safe for concurrent use.
+	Logbook struct {
+		mu         sync.Mutex
+		path       string
+		entries    []Entry_t // oldest first
+		current    *Entry_t  // while locked, or unlocked for less than config_UnlockTime
+		bestMer    float64
+		lastLocked time.Time
+	}
+)
+
+// Opens the logbook in the CSV file at path, which is created when the first entry is added
+func Open(path string) (*Logbook, error) {
+	l := &Logbook{path: path}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	for i, row := range rows {
+		if i == 0 {
+			continue // the header
+		}
+		entry, err := parseRow(row)
+		if err != nil {
+			return nil, fmt.Errorf("%v line %v: %w", path, i+1, err)
+		}
+		l.entries = append(l.entries, entry)
+	}
+	return l, nil
+}
+
+// Follows the demodulator status at now, and returns true when an entry has been added
+func (l *Logbook) Update(lmData lmClient.LmData_t, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	locked := lmData.Locked && lmData.FrequencyMHz > 0
+	if locked && !config_LogBeacon && math.Abs(lmData.FrequencyMHz-kBeaconMHz) < kSameStationMHz {
+		locked = false
+	}
+	if locked && l.current != nil && math.Abs(lmData.FrequencyMHz-l.current.FrequencyMHz) > kSameStationMHz {
+		// retuned to another station without an unlock
+		l.finish()
+	}
+
+	added := false
+	switch {
+	case locked:
+		if l.current == nil {
+			l.current = &Entry_t{
+				Start:        now.UTC(),
+				FrequencyMHz: lmData.FrequencyMHz,
+			}
+			l.bestMer = math.Inf(-1)
+		}
+		l.lastLocked = now
+		l.current.Duration = now.Sub(l.current.Start)
+		l.current.update(lmData, &l.bestMer)
+	case l.current != nil && now.Sub(l.lastLocked) >= config_UnlockTime:
+		added = l.finish()
+	}
+	return added
+}
+
+// Adds the current entry if it was locked for long enough
+func (l *Logbook) finish() bool {
+	entry := *l.current
+	l.current = nil
+	if entry.Duration < config_MinLockTime {
+		return false
+	}
+	l.entries = append(l.entries, entry)
+	if err := l.append(entry); err != nil {
+		log.Printf("ERROR logbook not saved: %v", err)
+	}
+	log.Printf("INFO logged %v at %.3f MHz for %v", entry.Callsign, entry.FrequencyMHz, entry.Duration.Round(time.Second))
+	return true
+}
+
+// Takes the status values that are known, keeping those with the best MER
+func (e *Entry_t) update(lmData lmClient.LmData_t, bestMer *float64) {
+	known := func(s string) bool { return s != "" && s != kDash }
+	if lmData.SymbolRateKS > 0 {
+		e.SymbolRateKS = lmData.SymbolRateKS
+	}
+	for _, v := range []struct {
+		to   *string
+		from string
+	}{
+		{&e.Mode, lmData.Mode},
+		{&e.Constellation, lmData.Constellation},
+		{&e.Fec, lmData.Fec},
+		{&e.Provider, lmData.Provider},
+		{&e.Service, lmData.Service},
+	} {
+		if known(v.from) {
+			*v.to = v.from
+		}
+	}
+	if mer, err := strconv.ParseFloat(lmData.DbMer, 64); err == nil && mer > *bestMer {
+		*bestMer = mer
+		e.DbMer = lmData.DbMer
+		e.DbMargin = strings.TrimPrefix(lmData.DbMargin, "D ")
+		if !known(e.DbMargin) {
+			e.DbMargin = ""
+		}
+	}
+	switch {
+	case lmData.ServiceInfo.Callsign != "":
+		e.Callsign = lmData.ServiceInfo.Callsign
+	case e.Callsign == "":
+		e.Callsign = e.Service
+	}
+}
+
+// Returns a copy of the entries, newest first, including the current lock
+func (l *Logbook) Entries() []Entry_t {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := make([]Entry_t, 0, len(l.entries)+1)
+	if l.current != nil {
+		entries = append(entries, *l.current)
+	}
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		entries = append(entries, l.entries[i])
+	}
+	return entries
+}
+
+// Writes every entry to w as CSV, oldest first
+func (l *Logbook) WriteCsv(w io.Writer) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for _, e := range l.entries {
+		cw.Write(e.row())
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Adds entry to the end of the file, with the header if it is new
+func (l *Logbook) append(entry Entry_t) error {
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		cw.Write(csvHeader)
+	}
+	cw.Write(entry.row())
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (e *Entry_t) row() []string {
+	return []string{
+		e.Start.Format(time.RFC3339),
+		fmt.Sprintf("%.0f", e.Duration.Seconds()),
+		fmt.Sprintf("%.3f", e.FrequencyMHz),
+		fmt.Sprintf("%.1f", e.SymbolRateKS),
+		e.Mode, e.Constellation, e.Fec,
+		e.DbMer, e.DbMargin,
+		e.Provider, e.Service, e.Callsign,
+	}
+}
+
+func parseRow(row []string) (Entry_t, error) {
+	if len(row) != len(csvHeader) {
+		return Entry_t{}, fmt.Errorf("%v fields, want %v", len(row), len(csvHeader))
+	}
+	start, err := time.Parse(time.RFC3339, row[0])
+	if err != nil {
+		return Entry_t{}, err
+	}
+	seconds, err := strconv.Atoi(row[1])
+	if err != nil {
+		return Entry_t{}, err
+	}
+	frequency, err := strconv.ParseFloat(row[2], 64)
+	if err != nil {
+		return Entry_t{}, err
+	}
+	symbolRate, err := strconv.ParseFloat(row[3], 64)
+	if err != nil {
+		return Entry_t{}, err
+	}
+	return Entry_t{
+		Start:         start.UTC(),
+		Duration:      time.Duration(seconds) * time.Second,
+		FrequencyMHz:  frequency,
+		SymbolRateKS:  symbolRate,
+		Mode:          row[4],
+		Constellation: row[5],
+		Fec:           row[6],
+		DbMer:         row[7],
+		DbMargin:      row[8],
+		Provider:      row[9],
+		Service:       row[10],
+		Callsign:      row[11],
+	}, nil
+}
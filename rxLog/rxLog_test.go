@@ -0,0 +1,106 @@
+package rxLog
+
+import (
+	"bytes"
+	"path/filepath"
+	"q100receiver/lmClient"
+	"strings"
+	"testing"
+	"time"
+)
+
+func locked(mhz float64, mer string) lmClient.LmData_t {
+	return lmClient.LmData_t{
+		Locked:        true,
+		FrequencyMHz:  mhz,
+		SymbolRateKS:  333,
+		Mode:          "DVB-S2",
+		Constellation: "QPSK",
+		Fec:           "2/3",
+		DbMer:         mer,
+		DbMargin:      "D 3.0",
+		Provider:      "QO-100",
+		Service:       "EA7KIR",
+	}
+}
+
+func TestLogbook(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logbook.csv")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	step := func(lmData lmClient.LmData_t, d time.Duration) bool {
+		added := false
+		for end := now.Add(d); now.Before(end); now = now.Add(time.Second) {
+			added = l.Update(lmData, now) || added
+		}
+		return added
+	}
+
+	// the beacon and short locks are not logged
+	step(locked(10491.50, "9.0"), time.Minute)
+	step(lmClient.LmData_t{}, time.Minute)
+	step(locked(10497.25, "9.0"), 3*time.Second)
+	if step(lmClient.LmData_t{}, time.Minute) || len(l.Entries()) != 0 {
+		t.Fatalf("logged %+v", l.Entries())
+	}
+
+	// a short unlock continues the entry, which keeps the best MER
+	step(locked(10497.25, "8.0"), 20*time.Second)
+	step(lmClient.LmData_t{}, 5*time.Second)
+	step(locked(10497.25, "9.5"), 20*time.Second)
+	if !step(lmClient.LmData_t{}, time.Minute) {
+		t.Fatalf("not logged")
+	}
+	entries := l.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("%v entries, want 1", len(entries))
+	}
+	if e := entries[0]; e.Duration != 44*time.Second || e.DbMer != "9.5" || e.DbMargin != "3.0" || e.Callsign != "EA7KIR" {
+		t.Errorf("logged %+v", e)
+	}
+
+	// the entry is read back from the file
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got bytes.Buffer
+	l.WriteCsv(&want)
+	reopened.WriteCsv(&got)
+	if got.String() != want.String() {
+		t.Errorf("reopened:\n%v\nwant:\n%v", got.String(), want.String())
+	}
+}
+
+func TestWriteAdif(t *testing.T) {
+	start := time.Date(2026, 1, 1, 23, 59, 30, 0, time.UTC)
+	l := &Logbook{entries: []Entry_t{
+		{
+			Start: start, Duration: time.Minute, FrequencyMHz: 10497.25, SymbolRateKS: 333,
+			Mode: "DVB-S2", Constellation: "QPSK", Fec: "2/3", DbMer: "9.5", DbMargin: "3.0", Callsign: "ea7kir",
+		},
+		{Start: start, Duration: time.Minute, FrequencyMHz: 10494.75, SymbolRateKS: 1000}, // no callsign
+	}}
+	var b strings.Builder
+	if err := l.WriteAdif(&b, start); err != nil {
+		t.Fatal(err)
+	}
+	adif := b.String()
+	for _, want := range []string{
+		"<ADIF_VER:5>3.1.4 ",
+		"<EOH>\n",
+		"<CALL:6>EA7KIR <QSO_DATE:8>20260101 <TIME_ON:6>235930 <QSO_DATE_OFF:8>20260102 <TIME_OFF:6>000030 ",
+		"<MODE:4>DATV <BAND:4>13cm <FREQ:9>2407.7500 <BAND_RX:3>3cm <FREQ_RX:10>10497.2500 ",
+		"<COMMENT:48>MER 9.5 dB margin 3.0 dB, 333 kS DVB-S2 QPSK 2/3 <EOR>\n",
+	} {
+		if !strings.Contains(adif, want) {
+			t.Errorf("no %q in\n%v", want, adif)
+		}
+	}
+	if n := strings.Count(adif, "<EOR>"); n != 1 {
+		t.Errorf("%v records, want 1", n)
+	}
+}
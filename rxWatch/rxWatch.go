@@ -0,0 +1,86 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package rxWatch
+
+import (
+	"q100receiver/rxControl"
+	"q100receiver/spClient"
+	"time"
+)
+
+const (
+	config_EmptyTime = 10 * time.Minute // a channel must be empty this long before a new carrier is alerted
+)
+
+type (
+	Alert_t struct {
+		Time    time.Time
+		Channel rxControl.Channel_t
+		Signal  spClient.Signal_t
+	}
+
+	channelState_t struct {
+		channel   rxControl.Channel_t
+		carrier   spClient.CarrierCount_t
+		isPresent bool      // present for spClient.CarrierFrames
+		lastSeen  time.Time // or when watching started
+	}
+
+	// A Watcher looks for new carriers in each channel except the beacon
+	Watcher struct {
+		emptyTime time.Duration
+		channels  []channelState_t
+	}
+)
+
+// Returns a Watcher for channels, where a carrier is new if its channel has
+// been empty for emptyTime, or config_EmptyTime if zero
+func NewWatcher(channels []rxControl.Channel_t, emptyTime time.Duration) *Watcher {
+	if emptyTime == 0 {
+		emptyTime = config_EmptyTime
+	}
+	w := &Watcher{emptyTime: emptyTime}
+	for _, ch := range channels {
+		if ch.Number == "00" {
+			continue
+		}
+		w.channels = append(w.channels, channelState_t{channel: ch, carrier: spClient.CarrierCount_t{MHz: ch.MHz}})
+	}
+	return w
+}
+
+// Starts watching at now. Every channel is treated as empty from now, so carriers
+// already on the transponder are not alerted.
+func (w *Watcher) Start(now time.Time) {
+	for i := range w.channels {
+		w.channels[i].carrier = spClient.CarrierCount_t{MHz: w.channels[i].channel.MHz}
+		w.channels[i].isPresent = false
+		w.channels[i].lastSeen = now
+	}
+}
+
+// Checks each channel in a spectrum frame received at now, and returns an Alert_t
+// for each carrier which has just appeared in a channel empty for the empty time
+func (w *Watcher) Update(spData *spClient.SpData_t, now time.Time) []Alert_t {
+	var alerts []Alert_t
+	for i := range w.channels {
+		ch := &w.channels[i]
+		signal, ok := ch.carrier.Update(spData)
+		if !ok {
+			ch.isPresent = false
+			continue
+		}
+		if !ch.carrier.Present() {
+			continue
+		}
+		if !ch.isPresent && now.Sub(ch.lastSeen) >= w.emptyTime {
+			alerts = append(alerts, Alert_t{Time: now, Channel: ch.channel, Signal: signal})
+		}
+		ch.isPresent = true
+		ch.lastSeen = now
+	}
+	return alerts
+}
@@ -0,0 +1,61 @@
+package rxWatch
+
+import (
+	"q100receiver/rxControl"
+	"q100receiver/spClient"
+	"testing"
+	"time"
+)
+
+// Returns a frame with a 2 dB noise floor and a carrier 6 dB above it at each of mhz
+func frameWith(mhz ...float64) *spClient.SpData_t {
+	const ypPerDb = 100.0 / 17
+	spData := &spClient.SpData_t{Yp: make([]float32, 918), NoiseFloorDb: 2}
+	for i := 1; i < 917; i++ {
+		f := spClient.XToMHz(100 * float32(i) / 918)
+		db := float32(2)
+		for _, m := range mhz {
+			if f > m-0.1 && f < m+0.1 {
+				db = 8
+			}
+		}
+		spData.Yp[i] = 3 + db*ypPerDb
+	}
+	return spData
+}
+
+func TestWatcher(t *testing.T) {
+	w := NewWatcher(rxControl.Channels(), time.Minute)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	w.Start(start)
+
+	// the beacon is never alerted, and a carrier at start is not new
+	now := start
+	frames := func(n int, spData *spClient.SpData_t) []Alert_t {
+		var alerts []Alert_t
+		for range n {
+			now = now.Add(100 * time.Millisecond)
+			alerts = append(alerts, w.Update(spData, now)...)
+		}
+		return alerts
+	}
+	if alerts := frames(20, frameWith(10491.50, 10496.00)); len(alerts) != 0 {
+		t.Errorf("got %v alerts at start", len(alerts))
+	}
+
+	// 10496.00 stays empty long enough, 10497.00 appears for the first time
+	frames(600, frameWith(10491.50))
+	alerts := frames(10, frameWith(10491.50, 10496.00, 10497.00))
+	if len(alerts) != 2 {
+		t.Fatalf("got %v alerts, want 2", len(alerts))
+	}
+	if alerts[0].Channel.Number != "14" || alerts[1].Channel.Number != "18" {
+		t.Errorf("got channels %v and %v, want 14 and 18", alerts[0].Channel.Number, alerts[1].Channel.Number)
+	}
+
+	// a short fade is not a new carrier
+	frames(20, frameWith(10491.50))
+	if alerts := frames(10, frameWith(10491.50, 10496.00)); len(alerts) != 0 {
+		t.Errorf("got %v alerts after a short fade", len(alerts))
+	}
+}
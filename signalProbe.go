@@ -0,0 +1,74 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package main
+
+import (
+	"fmt"
+	"q100receiver/spClient"
+
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
+	"github.com/ajstarks/giocanvas"
+)
+
+const (
+	kProbeWidth  = 17 // canvas units
+	kProbeHeight = 22
+)
+
+// A readout of the signal touched on the spectrum
+//
+//	The signal is measured again from every new frame, so the readout follows it.
+//	Touching where there is no signal hides the readout.
+type signalProbe_t struct {
+	active bool
+	x      float32 // spectrum coordinate, 0.0 to 100.0
+}
+
+// Handles touches on the spectrum canvas, which is width pixels wide
+func (p *signalProbe_t) update(gtx C, view *spectrumView_t, left float32, width int) {
+	event.Op(gtx.Ops, p)
+	for {
+		ev, ok := gtx.Event(pointer.Filter{Target: p, Kinds: pointer.Press})
+		if !ok {
+			break
+		}
+		if e, ok := ev.(pointer.Event); ok && e.Kind == pointer.Press {
+			p.x = view.fromCanvas(e.Position.X/float32(width)*100, left)
+			_, p.active = spData.SignalAt(p.x)
+		}
+	}
+}
+
+// Draws the readout beside the signal, keeping it inside the canvas
+func (p *signalProbe_t) draw(canvas *giocanvas.Canvas, view *spectrumView_t, left float32) {
+	if !p.active {
+		return
+	}
+	signal, ok := spData.SignalAt(p.x)
+	if !ok {
+		return
+	}
+	cx := view.toCanvas(spClient.MHzToX(signal.CentreMHz), left)
+	canvas.VLine(cx, 5, 88, 0.05, q100color.gfxBeacon)
+
+	bx := cx + 2
+	if bx+kProbeWidth > 99 {
+		bx = cx - 2 - kProbeWidth
+	}
+	bx = max(bx, 1)
+	const by float32 = 60
+	canvas.Rect(bx+kProbeWidth/2, by+kProbeHeight/2, kProbeWidth, kProbeHeight, q100color.gfxMarker)
+	lines := []string{
+		fmt.Sprintf("%.3f MHz", signal.CentreMHz),
+		fmt.Sprintf("BW   %.0f kHz", signal.BandwidthMHz*1000),
+		fmt.Sprintf("Peak %.1f dB", signal.PeakDb),
+		fmt.Sprintf("C/N  %.1f dB", signal.CnDb),
+	}
+	for i, line := range lines {
+		canvas.Text(bx+1, by+kProbeHeight-5-float32(i)*5, 1.5, line, q100color.gfxBeacon)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package main
+
+import (
+	"image"
+	"io"
+	"log"
+	"q100receiver/lmClient"
+	"strings"
+	"time"
+
+	"gioui.org/io/clipboard"
+	"gioui.org/layout"
+	"github.com/ajstarks/giocanvas"
+)
+
+const (
+	config_ReportTime = time.Minute // a report is shown this long, or until Report is touched again
+	kReportTextSize   = 5           // percent of the canvas width
+	kReportLineChars  = 30
+)
+
+// A signal report, shown large in place of the spectrum
+type signalReport_t struct {
+	text   string
+	locked bool
+	until  time.Time
+}
+
+// Hides a report being shown, or else composes one from lmData. Returns true
+// if there is a new report to send.
+func (r *signalReport_t) toggle(lmData lmClient.LmData_t, now time.Time) bool {
+	if r.showing(now) {
+		r.until = time.Time{}
+		return false
+	}
+	r.text, r.locked = lmData.Report()
+	if !r.locked {
+		r.text = "Not locked"
+	}
+	r.until = now.Add(config_ReportTime)
+	return r.locked
+}
+
+func (r *signalReport_t) showing(now time.Time) bool {
+	return now.Before(r.until)
+}
+
+// Copies the report to the clipboard and publishes it on the local API
+func (r *signalReport_t) send(gtx C) {
+	gtx.Execute(clipboard.WriteCmd{Type: "application/text", Data: io.NopCloser(strings.NewReader(r.text))})
+	localApi.publish("report", r.text)
+	log.Printf("INFO report: %v", r.text)
+}
+
+// Returns the report in large text
+func (ui *UI) q100_ReportPanel(gtx C, width, height int) D {
+	canvas := giocanvas.Canvas{
+		Width:   float32(width),
+		Height:  float32(height),
+		Context: gtx,
+		Theme:   ui.th,
+	}
+	canvas.Background(q100color.gfxBgd)
+
+	colour := q100color.labelWhite
+	if !ui.signalReport.locked {
+		colour = q100color.labelOrange
+	}
+	lines := wrapText(ui.signalReport.text, kReportLineChars)
+	const step = 25
+	y := 50 + float32(len(lines)-1)*step/2
+	for _, s := range lines {
+		canvas.CText(50, y, kReportTextSize, s, colour)
+		y -= step
+	}
+
+	return layout.Dimensions{
+		Size: image.Point{X: width, Y: height},
+	}
+}
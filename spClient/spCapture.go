@@ -0,0 +1,206 @@
+package spClient
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+/***********************************************************************
+*
+*	CAPTURE AND REPLAY
+*
+*	A capture file starts with kCaptureMagic, followed by one record per
+*	frame:
+*
+*		uint32	milliseconds since the capture started, little-endian
+*		uint16	frame length, little-endian
+*		[]byte	the frame, normally 1844 bytes
+*
+*	Files without kCaptureMagic are replayed as 1844 byte frames, one
+*	after another, every config_ReplayInterval.
+*
+************************************************************************/
+
+const (
+	config_ReplayInterval = 100 * time.Millisecond
+
+	kCaptureMagic      = "Q1SP"
+	kCaptureHeaderSize = 6
+)
+
+type (
+	// captures every frame read from src
+	recorder_t struct {
+		src    SpectrumSource
+		file   *os.File
+		start  time.Time
+		record []byte
+	}
+
+	replaySource_t struct {
+		file    *os.File
+		reader  *bufio.Reader
+		capture bool
+		speed   float64
+		start   time.Time // when the replay from the beginning of the file started
+		count   int
+	}
+)
+
+func newRecorder(src SpectrumSource, name string) (*recorder_t, error) {
+	file, err := os.Create(name)
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	if _, err = file.WriteString(kCaptureMagic); err != nil {
+		src.Close()
+		file.Close()
+		return nil, err
+	}
+	log.Printf("INFO recording spectrum to %v", name)
+	return &recorder_t{
+		src:    src,
+		file:   file,
+		start:  time.Now(),
+		record: make([]byte, 0, kCaptureHeaderSize+2048),
+	}, nil
+}
+
+// Reads a frame from src and appends it to the capture file
+func (r *recorder_t) Read(frame []byte) (int, error) {
+	n, err := r.src.Read(frame)
+	if err != nil {
+		return n, err
+	}
+	r.record = binary.LittleEndian.AppendUint32(r.record[:0], uint32(time.Since(r.start).Milliseconds()))
+	r.record = binary.LittleEndian.AppendUint16(r.record, uint16(n))
+	r.record = append(r.record, frame[:n]...)
+	if _, err := r.file.Write(r.record); err != nil {
+		log.Printf("ERROR failed to record spectrum: %v", err)
+	}
+	return n, nil
+}
+
+func (r *recorder_t) Close() error {
+	return errors.Join(r.src.Close(), r.file.Close())
+}
+
+// Reconnects src, if it can, and carries on recording
+func (r *recorder_t) reconnect() error {
+	if src, ok := r.src.(reconnector); ok {
+		return src.reconnect()
+	}
+	return errCannotReconnect
+}
+
+// Returns a source replaying name at speed times the original pace
+func newReplaySource(name string, speed float64) (*replaySource_t, error) {
+	if speed < 0 {
+		return nil, fmt.Errorf("bad replay speed %v", speed)
+	}
+	if speed == 0 {
+		speed = 1
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	r := &replaySource_t{
+		file:   file,
+		reader: bufio.NewReader(file),
+		speed:  speed,
+	}
+	magic, _ := r.reader.Peek(len(kCaptureMagic))
+	r.capture = bytes.Equal(magic, []byte(kCaptureMagic))
+	// a file without a whole frame would never deliver one
+	if err = r.rewind(); err == nil {
+		if _, _, err = r.next(make([]byte, kMaxFrameSize)); err == nil {
+			err = r.rewind()
+		} else if errors.Is(err, io.EOF) {
+			err = fmt.Errorf("%v has no complete frames", name)
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Waits until the next frame is due and reads it, starting again at the end of the file
+func (r *replaySource_t) Read(frame []byte) (int, error) {
+	offset, n, err := r.next(frame)
+	if errors.Is(err, io.EOF) {
+		if err = r.rewind(); err != nil {
+			return 0, err
+		}
+		offset, n, err = r.next(frame)
+	}
+	if err != nil {
+		return 0, err
+	}
+	time.Sleep(time.Until(r.start.Add(time.Duration(float64(offset) / r.speed))))
+	return n, nil
+}
+
+func (r *replaySource_t) Close() error {
+	return r.file.Close()
+}
+
+func (r *replaySource_t) rewind() error {
+	if _, err := r.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r.reader.Reset(r.file)
+	if r.capture {
+		if _, err := r.reader.Discard(len(kCaptureMagic)); err != nil {
+			return err
+		}
+	}
+	r.start = time.Now()
+	r.count = 0
+	return nil
+}
+
+// Reads the next frame and returns its offset from the start of the file.
+// A partial frame at the end of the file is treated as io.EOF.
+func (r *replaySource_t) next(frame []byte) (time.Duration, int, error) {
+	if !r.capture {
+		n, err := io.ReadFull(r.reader, frame[:kFrameSize])
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = io.EOF
+		}
+		offset := time.Duration(r.count) * config_ReplayInterval
+		r.count++
+		return offset, n, err
+	}
+
+	var header [kCaptureHeaderSize]byte
+	if _, err := io.ReadFull(r.reader, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = io.EOF
+		}
+		return 0, 0, err
+	}
+	offset := time.Duration(binary.LittleEndian.Uint32(header[0:4])) * time.Millisecond
+	n := int(binary.LittleEndian.Uint16(header[4:6]))
+	if n > len(frame) {
+		return 0, 0, fmt.Errorf("capture frame of %v bytes is too large", n)
+	}
+	if _, err := io.ReadFull(r.reader, frame[:n]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = io.EOF
+		}
+		return 0, 0, err
+	}
+	r.count++
+	return offset, n, nil
+}
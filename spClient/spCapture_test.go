@@ -0,0 +1,216 @@
+package spClient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns the frames in turn, then fails
+type framesSource_t struct {
+	frames [][]byte
+	closed bool
+}
+
+func (s *framesSource_t) Read(frame []byte) (int, error) {
+	if len(s.frames) == 0 {
+		return 0, errors.New("no more frames")
+	}
+	n := copy(frame, s.frames[0])
+	s.frames = s.frames[1:]
+	return n, nil
+}
+
+func (s *framesSource_t) Close() error {
+	s.closed = true
+	return nil
+}
+
+// Records frames at offsets into a capture file, and returns its name
+func recordCapture(t *testing.T, frames [][]byte, offsets []time.Duration) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "test.q1sp")
+	src := &framesSource_t{frames: frames}
+	r, err := newRecorder(src, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := make([]byte, kMaxFrameSize)
+	for _, offset := range offsets {
+		r.start = time.Now().Add(-offset)
+		if _, err := r.Read(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !src.closed {
+		t.Errorf("recorder did not close its source")
+	}
+	return name
+}
+
+func TestCaptureRoundTrip(t *testing.T) {
+	frames := [][]byte{
+		bytes.Repeat([]byte{1}, kFrameSize),
+		bytes.Repeat([]byte{2, 3}, 300),
+		bytes.Repeat([]byte{4}, kMaxFrameSize),
+	}
+	offsets := []time.Duration{0, 1234 * time.Millisecond, 3 * time.Hour}
+	name := recordCapture(t, frames, offsets)
+
+	r, err := newReplaySource(name, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if !r.capture {
+		t.Fatalf("capture not recognised")
+	}
+	frame := make([]byte, kMaxFrameSize)
+	for i := range frames {
+		offset, n, err := r.next(frame)
+		if err != nil {
+			t.Fatalf("frame %v: %v", i, err)
+		}
+		if offset != offsets[i] {
+			t.Errorf("frame %v: got offset %v, want %v", i, offset, offsets[i])
+		}
+		if !bytes.Equal(frame[:n], frames[i]) {
+			t.Errorf("frame %v: got %v bytes, not those recorded", i, n)
+		}
+	}
+}
+
+// Returns how long it takes to read count frames from name at speed
+func replayTime(t *testing.T, name string, speed float64, count int) time.Duration {
+	t.Helper()
+	src, err := NewSpectrumSource(SpConfig_t{Source: "replay", File: name, Speed: speed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	frame := make([]byte, kMaxFrameSize)
+	start := time.Now()
+	for range count {
+		if _, err := src.Read(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return time.Since(start)
+}
+
+func TestReplayPacing(t *testing.T) {
+	const slack = 50 * time.Millisecond
+	frame := make([]byte, kFrameSize)
+	capture := recordCapture(t, [][]byte{frame, frame, frame},
+		[]time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond})
+	raw := filepath.Join(t.TempDir(), "raw.bin")
+	if err := os.WriteFile(raw, make([]byte, 3*kFrameSize), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []struct {
+		name  string
+		file  string
+		speed float64
+		count int
+		want  time.Duration
+	}{
+		{"capture", capture, 1, 3, 200 * time.Millisecond},
+		{"capture at speed 2", capture, 2, 3, 100 * time.Millisecond},
+		{"capture repeated at speed 4", capture, 4, 6, 100 * time.Millisecond},
+		{"raw", raw, 1, 3, 2 * config_ReplayInterval},
+		{"raw at speed 4", raw, 4, 3, 2 * config_ReplayInterval / 4},
+	} {
+		got := replayTime(t, v.file, v.speed, v.count)
+		if got < v.want-slack/5 || got > v.want+slack {
+			t.Errorf("%v: took %v, want %v", v.name, got, v.want)
+		}
+	}
+}
+
+func TestReplayEmpty(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{
+		"empty":           nil,
+		"magic only":      []byte(kCaptureMagic),
+		"short header":    []byte(kCaptureMagic + "\x00\x00"),
+		"truncated frame": append([]byte(kCaptureMagic+"\x00\x00\x00\x00\x34\x07"), make([]byte, 100)...),
+		"short raw":       make([]byte, kFrameSize-1),
+	} {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if src, err := newReplaySource(file, 1); err == nil {
+			src.Close()
+			t.Errorf("%v: accepted", name)
+		}
+	}
+	if _, err := newReplaySource(filepath.Join(dir, "empty"), -1); err == nil {
+		t.Errorf("accepted a negative speed")
+	}
+}
+
+func TestReadSpectrumStopsOnError(t *testing.T) {
+	src := &framesSource_t{frames: [][]byte{make([]byte, kFrameSize)}}
+	spDataChan := make(chan SpData_t)
+	done := make(chan struct{})
+	go func() {
+		ReadSpectrum(context.Background(), src, nil, spDataChan)
+		close(done)
+	}()
+	if got := <-spDataChan; got.Error != "" {
+		t.Errorf("got error %q with the first frame", got.Error)
+	}
+	if got := <-spDataChan; !strings.Contains(got.Error, "no more frames") || got.Yp == nil {
+		t.Errorf("got error %q, want the read error with the last frame", got.Error)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("ReadSpectrum did not stop")
+	}
+	if !src.closed {
+		t.Errorf("source not closed")
+	}
+}
+
+// Fails to Read until reconnected, then returns one frame
+type flakySource_t struct {
+	framesSource_t
+	reconnects int
+}
+
+func (s *flakySource_t) reconnect() error {
+	s.reconnects++
+	s.frames = [][]byte{make([]byte, kFrameSize)}
+	return nil
+}
+
+func TestReadSpectrumReconnects(t *testing.T) {
+	src := &flakySource_t{}
+	spDataChan := make(chan SpData_t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ReadSpectrum(ctx, src, nil, spDataChan)
+
+	if got := <-spDataChan; !strings.Contains(got.Error, "reconnecting") {
+		t.Errorf("got error %q, want reconnecting", got.Error)
+	}
+	select {
+	case got := <-spDataChan:
+		if got.Error != "" || src.reconnects != 1 {
+			t.Errorf("got error %q after %v reconnects", got.Error, src.reconnects)
+		}
+	case <-time.After(2*config_ReconnectMin + time.Second):
+		t.Fatalf("no frame after reconnecting")
+	}
+}
@@ -7,22 +7,35 @@ package spClient
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"time"
-
-	"golang.org/x/net/websocket"
 )
 
 const (
 	config_Origin = "https://eshail.batc.org.uk/"
 	config_Url    = "wss://eshail.batc.org.uk/wb/fft/fft_ea7kirsatcontroller:443/wss"
 	kNumPoints    = 918
+	kFrameSize    = 1844
+	kStartMHz     = 10490.5 // frequency of the first point
+	kSpanMHz      = 9.0     // frequency span of kNumPoints
+
+	config_ReconnectMin = time.Second // after a read fails, doubling to config_ReconnectMax
+	config_ReconnectMax = time.Minute
 )
 
 type (
 	SpData_t struct {
-		Yp          []float32
-		BeaconLevel float32
+		Yp           []float32
+		BeaconLevel  float32 // on the 0 to 100 scale
+		NoiseFloorDb float32
+		BeaconDb     float32
+		BeaconCnDb   float32   // beacon above the noise floor
+		Average      []float32 // nil unless selected by CmdToggleAverage
+		PeakHold     []float32 // nil unless selected by CmdTogglePeakHold
+		MaxHold      []float32 // nil unless selected by CmdToggleMaxHold
+		Error        string    // why frames have stopped, with Yp the last frame, or "" for a new frame
 	}
 )
 
@@ -30,14 +43,18 @@ var (
 	Xp = make([]float32, kNumPoints) // x coordinates from 0.0 to 100.0
 )
 
-func ReadSpectrumServer(ctx context.Context, spDataChan chan<- SpData_t) {
+// Reads frames from src and sends them to spDataChan, normalized to 0 to 100,
+// with any traces selected by spCmdChan, until ctx is cancelled. If a read
+// fails, the error is sent, and src is reconnected if it can be, or else closed.
+func ReadSpectrum(ctx context.Context, src SpectrumSource, spCmdChan <-chan SpCmd_t, spDataChan chan<- SpData_t) {
 	var (
-		ws     *websocket.Conn
-		err    error
-		n      int
-		bytes  = make([]byte, 2048) // larger than 1844
-		spData = SpData_t{
-			Yp:          make([]float32, kNumPoints),
+		err     error
+		n       int
+		bytes   = make([]byte, kMaxFrameSize)
+		sorted  = make([]float32, kNumPoints)
+		decoder frameDecoder_t
+		traces  traces_t
+		spData  = SpData_t{
 			BeaconLevel: 0.5,
 		}
 	)
@@ -48,63 +65,109 @@ func ReadSpectrumServer(ctx context.Context, spDataChan chan<- SpData_t) {
 	}
 	Xp[kNumPoints-1] = 100
 
-	// TODO: needs a timeout. see https://pkg.go.dev/nhooyr.io/websocket
-	//	which uses: ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-	const MAXTRIES = 10
-	for i := 1; i <= MAXTRIES; i++ {
-		log.Printf("INFO Dial attempt %v", i)
-		ws, err = websocket.Dial(config_Url, "", config_Origin)
-		if err == nil {
-			break
+	// keep accepting commands, so the UI never waits for itself
+	send := func() bool {
+		for {
+			select {
+			case spDataChan <- spData:
+				return true
+			case cmd := <-spCmdChan:
+				traces.toggle(cmd)
+			case <-ctx.Done():
+				src.Close()
+				log.Printf("CANCEL ----- spClient has cancelled")
+				return false
+			}
 		}
-		if i == MAXTRIES {
-			log.Fatalf("FATAL Dial Aborted after %v attemps\n", i)
+	}
+	// sends the error until src reconnects, and returns false if it cannot
+	reconnect := func(err error) bool {
+		log.Printf("ERROR spectrum read failed: %v", err)
+		r, ok := src.(reconnector)
+		if !ok {
+			src.Close()
+			spData.Error = fmt.Sprintf("Spectrum has stopped: %v", err)
+			send()
+			return false
+		}
+		for wait := config_ReconnectMin; ; wait = min(2*wait, config_ReconnectMax) {
+			spData.Error = fmt.Sprintf("Spectrum reconnecting in %v: %v", wait, err)
+			if !send() {
+				return false
+			}
+			select {
+			case <-ctx.Done():
+				src.Close()
+				log.Printf("CANCEL ----- spClient has cancelled")
+				return false
+			case <-time.After(wait):
+			}
+			if err = r.reconnect(); err == nil {
+				log.Printf("INFO spectrum has reconnected")
+				spData.Error = ""
+				return true
+			}
+			if errors.Is(err, errCannotReconnect) {
+				src.Close()
+				spData.Error = fmt.Sprintf("Spectrum has stopped: %v", err)
+				send()
+				return false
+			}
+			log.Printf("ERROR spectrum reconnect failed: %v", err)
 		}
-		time.Sleep(time.Millisecond * 500)
 	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			ws.Close()
+			src.Close()
 			log.Printf("CANCEL ----- spClient has cancelled")
 			return
+		case cmd := <-spCmdChan:
+			traces.toggle(cmd)
 		default:
 		}
 
-		if n, err = ws.Read(bytes); err != nil {
-			// TODO: this is a PROBLEM - need to find a better way to recover
-			log.Fatalf("FATAL Read failed: %v", err)
+		if n, err = src.Read(bytes); err != nil {
+			if !reconnect(err) {
+				return
+			}
+			continue
 		}
-		if n != 1844 {
-			log.Printf("WARN reading : bytes != 1844\n")
+		// a new Yp for every frame, as the last one is still being read by the UI and the watchers
+		spData.Yp = make([]float32, kNumPoints)
+		if err = decoder.decode(bytes[:n], spData.Yp); err != nil {
+			log.Printf("WARN reading : %v", err)
 			continue
 		}
 
-		// process the bytes
-		// var count = 0
-		for i := 0; i < 1836; {
-			word := uint16(bytes[i]) + uint16(bytes[i+1])<<8
-			// count++
-			// log.Printf("INFO count = %v\n", count)
-			if word < 8192 {
-				word = 8192
-			}
-			spData.Yp[i/2] = float32(word-uint16(8192)) / float32(520) // normalize to 0 to 100
-			i += 2
-		}
-		// log.Printf("INFO count = %v\n", count)
-		spData.Yp[0] = 0
-		spData.Yp[kNumPoints-1] = 0
+		spData.measure(sorted)
 
-		spData.BeaconLevel = 0
-		for i := 32; i <= 133; i++ { // beacon center is 103
-			spData.BeaconLevel += spData.Yp[i]
-		}
-		spData.BeaconLevel = spData.BeaconLevel / 103
-		// log.Printf("INFO beacon level %v : Yp[i] %v", spData.BeaconLevel, spData.Yp[103])
+		traces.update(spData.Yp)
+		traces.copyTo(&spData)
 
-		spDataChan <- spData
+		if !send() {
+			return
+		}
+	}
+}
 
+// Encodes kNumPoints values on the 0 to 100 scale as a frame from the BATC server
+func encodeFrame(yp []float64, frame []byte) {
+	for i, y := range yp {
+		word := uint16(8192 + min(max(y, 0), 100)*520)
+		frame[2*i] = byte(word)
+		frame[2*i+1] = byte(word >> 8)
 	}
+	clear(frame[2*kNumPoints : kFrameSize])
+}
+
+// Returns the x coordinate, from 0.0 to 100.0, of a frequency in MHz
+func MHzToX(mhz float64) float32 {
+	return float32(100 * (mhz - kStartMHz) / kSpanMHz)
+}
+
+// Returns the frequency in MHz at an x coordinate
+func XToMHz(x float32) float64 {
+	return kStartMHz + kSpanMHz*float64(x)/100
 }
@@ -0,0 +1,100 @@
+package spClient
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+)
+
+/***********************************************************************
+*
+*	BATC WIDEBAND SPECTRUM FRAME
+*
+*	A frame is a run of little-endian uint16 power words, one per point
+*	from kStartMHz across kSpanMHz, followed by a kTrailerSize byte
+*	trailer. The server normally sends kNumPoints words in a kFrameSize
+*	byte frame.
+*
+*	NOT DONE: the trailer fields are not decoded. They are not documented,
+*	and no capture from the BATC server is available to work them out, so
+*	the trailer is skipped. A capture made with -sprecord while connected
+*	to the server is needed, both for that and as a test fixture.
+*
+*	A word of kWordOffset is the bottom of the 0 to 100 scale, and each
+*	kWordsPerUnit above it is one unit of the scale.
+*
+************************************************************************/
+
+const (
+	config_ClippedFraction = 0.1 // of the points, clipped before the scaling is reported as changed
+
+	kMaxFrameSize   = 8192 // larger than any frame the server is expected to send
+	kTrailerSize    = 8
+	kMinFramePoints = 64
+	kWordOffset     = 8192
+	kWordsPerUnit   = 520
+)
+
+type (
+	// Decodes frames into kNumPoints values on the 0 to 100 scale
+	frameDecoder_t struct {
+		points    int // in the last frame
+		clipped   int // points outside the 0 to 100 scale in the last frame
+		values    []float32
+		lastSize  int
+		isClipped bool // already reported
+	}
+)
+
+// Decodes frame into yp, which has kNumPoints values. Frames with other than
+// kNumPoints points are resampled. yp is unchanged if the frame is invalid.
+func (d *frameDecoder_t) decode(frame []byte, yp []float32) error {
+	n := len(frame)
+	switch {
+	case n%2 != 0:
+		return fmt.Errorf("frame of %v bytes has an odd length", n)
+	case n < kTrailerSize+2*kMinFramePoints:
+		return fmt.Errorf("frame of %v bytes is too short", n)
+	}
+	points := (n - kTrailerSize) / 2
+
+	if n != d.lastSize && n != kFrameSize {
+		log.Printf("INFO spectrum frames are %v bytes, resampling %v points to %v", n, points, kNumPoints)
+	}
+	d.lastSize = n
+	d.points = points
+
+	d.clipped = 0
+	d.values = d.values[:0]
+	for i := range points {
+		v := (float32(binary.LittleEndian.Uint16(frame[2*i:])) - kWordOffset) / kWordsPerUnit
+		if v < 0 || v > 100 {
+			d.clipped++
+		}
+		d.values = append(d.values, min(max(v, 0), 100))
+	}
+
+	if points == kNumPoints {
+		copy(yp, d.values)
+	} else {
+		// linear interpolation between the nearest two points
+		for i := range yp {
+			pos := float32(i) * float32(points-1) / float32(kNumPoints-1)
+			j := min(int(pos), points-2)
+			frac := pos - float32(j)
+			yp[i] = d.values[j] + (d.values[j+1]-d.values[j])*frac
+		}
+	}
+	yp[0] = 0
+	yp[kNumPoints-1] = 0
+
+	switch {
+	case d.clipped > int(config_ClippedFraction*float64(points)) && !d.isClipped:
+		log.Printf("WARN %v of %v spectrum points are off the scale, the server scaling may have changed", d.clipped, points)
+		d.isClipped = true
+	case d.clipped <= int(config_ClippedFraction*float64(points)) && d.isClipped:
+		log.Printf("INFO spectrum points are back on the scale")
+		d.isClipped = false
+	}
+	return nil
+}
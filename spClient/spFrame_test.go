@@ -0,0 +1,147 @@
+package spClient
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+)
+
+// Returns a frame of words followed by the trailer
+func frameOf(words []uint16, trailer [4]uint16) []byte {
+	frame := make([]byte, 0, 2*len(words)+kTrailerSize)
+	for _, w := range append(words, trailer[:]...) {
+		frame = binary.LittleEndian.AppendUint16(frame, w)
+	}
+	return frame
+}
+
+// synth.q1sp was recorded with -sprecord from the synthetic source, whose
+// frames are made by encodeFrame, so this only tests the decoder against
+// that encoder through a capture and replay, not against the BATC server.
+// Its carriers are config_SynthCarriers on a noise floor of config_SynthNoiseFloor.
+func TestDecodeSyntheticCapture(t *testing.T) {
+	src, err := NewSpectrumSource(SpConfig_t{Source: "replay", File: "testdata/synth.q1sp", Speed: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	const tolerance = config_SynthNoiseDb
+	wantFloor := ypToDb(config_SynthNoiseFloor)
+	var d frameDecoder_t
+	frame := make([]byte, kMaxFrameSize)
+	sorted := make([]float32, kNumPoints)
+	for i := range 3 {
+		n, err := src.Read(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != kFrameSize {
+			t.Fatalf("frame %v: got %v bytes, want %v", i, n, kFrameSize)
+		}
+		spData := SpData_t{Yp: make([]float32, kNumPoints)}
+		if err := d.decode(frame[:n], spData.Yp); err != nil {
+			t.Fatalf("frame %v: %v", i, err)
+		}
+		if d.points != kNumPoints || d.clipped != 0 {
+			t.Errorf("frame %v: %v points, %v clipped", i, d.points, d.clipped)
+		}
+
+		spData.measure(sorted)
+		if !nearDb(spData.NoiseFloorDb, wantFloor, tolerance) {
+			t.Errorf("frame %v: noise floor %v dB, want %v", i, spData.NoiseFloorDb, wantFloor)
+		}
+		for _, c := range config_SynthCarriers {
+			// the mean across the flat top, which is signal and noise
+			lo, hi := pointRange(c.FrequencyMHz, c.SymbolRateKS/2000*(1-kRollOff))
+			got := meanDb(spData.Yp, lo, hi) - wantFloor
+			want := float32(10 * math.Log10(1+math.Pow(10, c.LevelDb/10)))
+			if !nearDb(got, want, tolerance) {
+				t.Errorf("frame %v: carrier at %v MHz is %v dB above the floor, want %v", i, c.FrequencyMHz, got, want)
+			}
+		}
+		if want := float32(10 * math.Log10(1+math.Pow(10, 0.8))); !nearDb(spData.BeaconCnDb, want, tolerance) {
+			t.Errorf("frame %v: beacon C/N %v dB, want %v", i, spData.BeaconCnDb, want)
+		}
+	}
+}
+
+func nearDb(got, want, tolerance float32) bool {
+	return got > want-tolerance && got < want+tolerance
+}
+
+func TestDecodeScaling(t *testing.T) {
+	words := make([]uint16, kNumPoints)
+	for i := range words {
+		words[i] = kWordOffset + 10*kWordsPerUnit
+	}
+	words[100] = kWordOffset + 50*kWordsPerUnit
+	words[200] = 0
+	words[300] = 65535
+	frame := frameOf(words, [4]uint16{1, 2, 3, 0xBEEF})
+
+	var d frameDecoder_t
+	yp := make([]float32, kNumPoints)
+	if err := d.decode(frame, yp); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range map[int]float32{0: 0, 1: 10, 100: 50, 200: 0, 300: 100, kNumPoints - 1: 0} {
+		if yp[i] != want {
+			t.Errorf("point %v: got %v, want %v", i, yp[i], want)
+		}
+	}
+	if d.clipped != 2 {
+		t.Errorf("got %v clipped points, want 2", d.clipped)
+	}
+	if d.points != kNumPoints {
+		t.Errorf("got %v points, the trailer was decoded as points", d.points)
+	}
+
+	// a server sending a different scale is clipped, not wrapped
+	for i := range words {
+		words[i] = 100
+	}
+	if err := d.decode(frameOf(words, [4]uint16{}), yp); err != nil {
+		t.Fatal(err)
+	}
+	if !d.isClipped || slices.Max(yp) != 0 {
+		t.Errorf("isClipped %v, max %v", d.isClipped, slices.Max(yp))
+	}
+}
+
+func TestDecodeResampled(t *testing.T) {
+	for _, points := range []int{459, 1836} {
+		words := make([]uint16, points)
+		for i := range words {
+			words[i] = uint16(kWordOffset + float64(kWordsPerUnit)*100*float64(i)/float64(points-1))
+		}
+		var d frameDecoder_t
+		yp := make([]float32, kNumPoints)
+		if err := d.decode(frameOf(words, [4]uint16{}), yp); err != nil {
+			t.Fatal(err)
+		}
+		if d.points != points {
+			t.Errorf("got %v points, want %v", d.points, points)
+		}
+		for _, i := range []int{1, 459, 916} {
+			if want := 100 * float32(i) / (kNumPoints - 1); !near(yp[i], want) {
+				t.Errorf("%v points: point %v is %v, want %v", points, i, yp[i], want)
+			}
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	yp := make([]float32, kNumPoints)
+	yp[1] = 42
+	var d frameDecoder_t
+	for _, n := range []int{0, 1, kFrameSize - 1, kTrailerSize + 2*kMinFramePoints - 2} {
+		if err := d.decode(make([]byte, n), yp); err == nil {
+			t.Errorf("accepted a frame of %v bytes", n)
+		}
+	}
+	if yp[1] != 42 {
+		t.Errorf("an invalid frame changed yp")
+	}
+}
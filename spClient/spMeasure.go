@@ -0,0 +1,72 @@
+package spClient
+
+import (
+	"math"
+	"slices"
+)
+
+/***********************************************************************
+*
+*	NOISE FLOOR, BEACON AND C/N
+*
+*	The server scales 17 dB across the 0 to 100 range, so the graticule
+*	has a line every kYpPerDb with 0 dB at kZeroDbYp.
+*
+************************************************************************/
+
+const (
+	kYpPerDb  = 100.0 / 17
+	kZeroDbYp = 3.0
+
+	kBeaconMHz       = 10491.50
+	kBeaconHalfWidth = 0.4 // MHz, within the flat top of the 1.5 MS beacon
+
+	// the noise floor is the mean of these percentiles of the spectrum, which
+	// leaves out the gaps at either end and any occupied channels
+	kNoiseLoPercentile = 10
+	kNoiseHiPercentile = 30
+)
+
+// Converts a value on the 0 to 100 scale to dB
+func ypToDb(yp float32) float32 {
+	return (yp - kZeroDbYp) / kYpPerDb
+}
+
+// Converts dB to a value on the 0 to 100 scale
+func dbToYp(db float32) float32 {
+	return db*kYpPerDb + kZeroDbYp
+}
+
+// Returns the first and last points within halfWidth MHz of mhz
+func pointRange(mhz, halfWidth float64) (int, int) {
+	lo := int(math.Ceil((mhz - halfWidth - kStartMHz) / kSpanMHz * kNumPoints))
+	hi := int(math.Floor((mhz + halfWidth - kStartMHz) / kSpanMHz * kNumPoints))
+	return max(lo, 0), min(hi, kNumPoints-1)
+}
+
+// Returns the mean of yp[lo] to yp[hi], in dB
+func meanDb(yp []float32, lo, hi int) float32 {
+	sum := float32(0)
+	for _, y := range yp[lo : hi+1] {
+		sum += y
+	}
+	return ypToDb(sum / float32(hi-lo+1))
+}
+
+// Returns the noise floor of yp in dB, estimated from the quietest points
+func noiseFloorDb(yp []float32, sorted []float32) float32 {
+	sorted = append(sorted[:0], yp[1:len(yp)-1]...) // yp[0] and the last are always 0
+	slices.Sort(sorted)
+	lo := len(sorted) * kNoiseLoPercentile / 100
+	hi := len(sorted) * kNoiseHiPercentile / 100
+	return meanDb(sorted, lo, hi)
+}
+
+// Sets the noise floor, beacon level and beacon C/N from spData.Yp
+func (spData *SpData_t) measure(sorted []float32) {
+	spData.NoiseFloorDb = noiseFloorDb(spData.Yp, sorted)
+	lo, hi := pointRange(kBeaconMHz, kBeaconHalfWidth)
+	spData.BeaconDb = meanDb(spData.Yp, lo, hi)
+	spData.BeaconCnDb = spData.BeaconDb - spData.NoiseFloorDb
+	spData.BeaconLevel = dbToYp(spData.BeaconDb)
+}
@@ -0,0 +1,140 @@
+package spClient
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Returns a frame with the noise floor at floorDb and each carrier's flat top
+// at its LevelDb above the floor
+func syntheticYp(floorDb float32, carriers []Carrier_t) []float32 {
+	yp := make([]float32, kNumPoints)
+	for i := 1; i < kNumPoints-1; i++ {
+		f := kStartMHz + kSpanMHz*float64(i)/kNumPoints
+		db := floorDb
+		for _, c := range carriers {
+			if math.Abs(f-c.FrequencyMHz) < c.SymbolRateKS/2000 {
+				db = floorDb + float32(c.LevelDb)
+			}
+		}
+		yp[i] = dbToYp(db)
+	}
+	return yp
+}
+
+func near(got, want float32) bool {
+	return math.Abs(float64(got-want)) < 0.05
+}
+
+func TestMeasure(t *testing.T) {
+	tests := []struct {
+		name     string
+		floorDb  float32
+		carriers []Carrier_t
+		beaconDb float32
+	}{
+		{"beacon only", 2, []Carrier_t{{10491.50, 1500, 9}}, 11},
+		{"weak beacon", 4, []Carrier_t{{10491.50, 1500, 1.5}}, 5.5},
+		{"no beacon", 3, nil, 3},
+		{"busy transponder", 2, []Carrier_t{
+			{10491.50, 1500, 8},
+			{10493.25, 1000, 6},
+			{10494.75, 1000, 5},
+			{10496.25, 1000, 7},
+			{10497.75, 500, 4},
+			{10498.50, 333, 3},
+			{10499.25, 333, 5},
+		}, 10},
+	}
+	sorted := make([]float32, kNumPoints)
+	for _, tt := range tests {
+		spData := SpData_t{Yp: syntheticYp(tt.floorDb, tt.carriers)}
+		spData.measure(sorted)
+		if !near(spData.NoiseFloorDb, tt.floorDb) {
+			t.Errorf("%v: noise floor %v dB, want %v", tt.name, spData.NoiseFloorDb, tt.floorDb)
+		}
+		if !near(spData.BeaconDb, tt.beaconDb) {
+			t.Errorf("%v: beacon %v dB, want %v", tt.name, spData.BeaconDb, tt.beaconDb)
+		}
+		if !near(spData.BeaconCnDb, tt.beaconDb-tt.floorDb) {
+			t.Errorf("%v: C/N %v dB, want %v", tt.name, spData.BeaconCnDb, tt.beaconDb-tt.floorDb)
+		}
+		if !near(spData.BeaconLevel, dbToYp(tt.beaconDb)) {
+			t.Errorf("%v: beacon level %v, want %v", tt.name, spData.BeaconLevel, dbToYp(tt.beaconDb))
+		}
+	}
+}
+
+func TestDbScale(t *testing.T) {
+	// the graticule labels 5, 10 and 15 dB on these lines
+	for _, db := range []float32{5, 10, 15} {
+		if got, want := dbToYp(db), 3+db*5.88235; !near(got, want) {
+			t.Errorf("%v dB at %v, want %v", db, got, want)
+		}
+		if got := ypToDb(dbToYp(db)); !near(got, db) {
+			t.Errorf("%v dB round trip gave %v", db, got)
+		}
+	}
+}
+
+func TestMeasureSyntheticSource(t *testing.T) {
+	carriers := []Carrier_t{{10491.50, 1500, 8}, {10497.25, 333, 5}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	spDataChan := make(chan SpData_t)
+	go ReadSpectrum(ctx, newSyntheticSource(carriers), nil, spDataChan)
+
+	spData := <-spDataChan
+	// the synthetic beacon is 8 dB above the noise, plus the noise itself
+	if want := 10 * math.Log10(1+math.Pow(10, 0.8)); math.Abs(float64(spData.BeaconCnDb)-want) > 0.5 {
+		t.Errorf("C/N %v dB, want about %.1f", spData.BeaconCnDb, want)
+	}
+	if want := ypToDb(config_SynthNoiseFloor); math.Abs(float64(spData.NoiseFloorDb-want)) > 0.5 {
+		t.Errorf("noise floor %v dB, want about %v", spData.NoiseFloorDb, want)
+	}
+}
+
+func TestSignalAt(t *testing.T) {
+	spData := SpData_t{Yp: syntheticYp(2, []Carrier_t{{10491.50, 1500, 9}, {10496.25, 1000, 6}})}
+	spData.measure(make([]float32, kNumPoints))
+
+	// a touch just beside the carrier still finds it
+	signal, ok := spData.SignalAt(MHzToX(10496.25 + 0.52))
+	if !ok {
+		t.Fatalf("no signal at 10496.25")
+	}
+	if math.Abs(signal.CentreMHz-10496.25) > 0.02 {
+		t.Errorf("centre %v MHz, want 10496.25", signal.CentreMHz)
+	}
+	if math.Abs(signal.BandwidthMHz-1.0) > 0.03 {
+		t.Errorf("bandwidth %v MHz, want 1.0", signal.BandwidthMHz)
+	}
+	if !near(signal.PeakDb, 8) || !near(signal.CnDb, 6) {
+		t.Errorf("peak %v dB, C/N %v dB, want 8 and 6", signal.PeakDb, signal.CnDb)
+	}
+
+	if _, ok := spData.SignalAt(MHzToX(10498.50)); ok {
+		t.Errorf("found a signal in an empty channel")
+	}
+}
+
+func TestFramesNotShared(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	spDataChan := make(chan SpData_t)
+	go ReadSpectrum(ctx, newSyntheticSource([]Carrier_t{{10491.50, 1500, 8}}), nil, spDataChan)
+
+	first := <-spDataChan
+	saved := slices.Clone(first.Yp)
+	second := <-spDataChan
+	if &first.Yp[0] == &second.Yp[0] {
+		t.Fatalf("frames share Yp")
+	}
+	<-spDataChan
+	if !slices.Equal(first.Yp, saved) {
+		t.Errorf("first frame changed by later frames")
+	}
+}
@@ -0,0 +1,279 @@
+package spClient
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"math/cmplx"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+/***********************************************************************
+*
+*	LOCAL SDR SPECTRUM
+*
+*	Computes the spectrum from an rtl_tcp IQ stream, so a station with a
+*	second SDR has a spectrum without the BATC server. The IQ can also be
+*	read from a file of rtl_tcp samples, ie. "file:iq.bin"
+*
+*	Only SampleRate around CentreMHz is covered, so an RTL-SDR at
+*	2.4 MS/s shows about a quarter of the transponder. The rest is left
+*	at the bottom of the scale.
+*
+************************************************************************/
+
+const (
+	config_SdrSampleRate = 2400000 // Hz
+	config_SdrCentreMHz  = 10495.0
+	config_SdrLnbLoMHz   = 9750.0 // until SetLnbLo gives the calibrated LO
+	config_SdrInterval   = 100 * time.Millisecond
+	config_SdrFloor      = 10.0 // noise floor on the 0 to 100 scale
+
+	kSdrFftSize    = 2048
+	kSdrUsable     = 0.9 // fraction of SampleRate outside the anti-alias roll-off
+	kRtlTcpSetFreq = 0x01
+	kRtlTcpSetRate = 0x02
+	kRtlTcpSetGain = 0x03 // gain mode, 0 is automatic
+)
+
+type (
+	sdrSource_t struct {
+		conn       io.ReadCloser
+		isFile     bool
+		sampleRate float64
+		centreMHz  float64
+		iq         []byte
+		window     []float64
+		fft        []complex128
+		power      []float64 // average power of each FFT bin
+		yp         []float64
+		db         []float64 // of the points covered, for the noise floor
+		lnbLoMHz   float64   // that the SDR is tuned for
+	}
+)
+
+var lnbLoMHz atomic.Uint64 // float64 bits, or 0 for config_SdrLnbLoMHz
+
+// Sets the LNB LO from the calibration by lmClient, to which the SDR is retuned.
+// Safe for concurrent use.
+func SetLnbLo(mhz float64) {
+	lnbLoMHz.Store(math.Float64bits(mhz))
+}
+
+// Returns the LO set by SetLnbLo, or config_SdrLnbLoMHz
+func currentLnbLo() float64 {
+	if mhz := math.Float64frombits(lnbLoMHz.Load()); mhz > 0 {
+		return mhz
+	}
+	return config_SdrLnbLoMHz
+}
+
+// Returns a source connected to rtl_tcp at address, or reading "file:name"
+func newSdrSource(address string, sampleRate, centreMHz float64) (*sdrSource_t, error) {
+	if sampleRate == 0 {
+		sampleRate = config_SdrSampleRate
+	}
+	if centreMHz == 0 {
+		centreMHz = config_SdrCentreMHz
+	}
+	s := &sdrSource_t{
+		sampleRate: sampleRate,
+		centreMHz:  centreMHz,
+		iq:         make([]byte, 2*int(sampleRate*config_SdrInterval.Seconds())),
+		window:     make([]float64, kSdrFftSize),
+		fft:        make([]complex128, kSdrFftSize),
+		power:      make([]float64, kSdrFftSize),
+		yp:         make([]float64, kNumPoints),
+		db:         make([]float64, 0, kNumPoints),
+	}
+	for i := range s.window { // Hann
+		s.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/kSdrFftSize)
+	}
+
+	if name, ok := strings.CutPrefix(address, "file:"); ok {
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		s.conn = file
+		s.isFile = true
+		return s, nil
+	}
+
+	log.Printf("INFO rtl_tcp will connect to %v for %.2f MHz at %.1f MS/s", address, centreMHz, sampleRate/1e6)
+	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	if err = s.setup(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reads the rtl_tcp header and sets the frequency, sample rate and gain
+func (s *sdrSource_t) setup(conn net.Conn) error {
+	var header [12]byte // "RTL0", tuner type, gain count
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return err
+	}
+	if string(header[:4]) != "RTL0" {
+		return errors.New("not an rtl_tcp server")
+	}
+	log.Printf("INFO rtl_tcp tuner type %v", binary.BigEndian.Uint32(header[4:8]))
+
+	if err := rtlTcpCommand(conn, kRtlTcpSetRate, uint32(s.sampleRate)); err != nil {
+		return err
+	}
+	if err := s.tune(conn); err != nil {
+		return err
+	}
+	return rtlTcpCommand(conn, kRtlTcpSetGain, 0)
+}
+
+// Tunes to centreMHz with the current LNB LO
+func (s *sdrSource_t) tune(conn io.Writer) error {
+	s.lnbLoMHz = currentLnbLo()
+	log.Printf("INFO rtl_tcp tuned to %.3f MHz for an LNB LO of %.3f MHz", s.centreMHz-s.lnbLoMHz, s.lnbLoMHz)
+	return rtlTcpCommand(conn, kRtlTcpSetFreq, uint32(math.Round((s.centreMHz-s.lnbLoMHz)*1e6)))
+}
+
+func rtlTcpCommand(conn io.Writer, cmd byte, value uint32) error {
+	var b [5]byte
+	b[0] = cmd
+	binary.BigEndian.PutUint32(b[1:], value)
+	_, err := conn.Write(b[:])
+	return err
+}
+
+// Reads config_SdrInterval of IQ samples and encodes their spectrum as a frame
+func (s *sdrSource_t) Read(frame []byte) (int, error) {
+	start := time.Now()
+	if conn, ok := s.conn.(net.Conn); ok && currentLnbLo() != s.lnbLoMHz {
+		if err := s.tune(conn); err != nil {
+			return 0, err
+		}
+	}
+	if err := s.readIQ(); err != nil {
+		return 0, err
+	}
+	s.averagePower()
+	s.resample()
+	encodeFrame(s.yp, frame)
+	if s.isFile {
+		time.Sleep(time.Until(start.Add(config_SdrInterval)))
+	}
+	return kFrameSize, nil
+}
+
+func (s *sdrSource_t) Close() error {
+	return s.conn.Close()
+}
+
+// Fills s.iq, starting a file again from the beginning at its end
+func (s *sdrSource_t) readIQ() error {
+	_, err := io.ReadFull(s.conn, s.iq)
+	if s.isFile && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
+		if _, err = s.conn.(*os.File).Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		_, err = io.ReadFull(s.conn, s.iq)
+	}
+	return err
+}
+
+// Averages the power of every FFT of s.iq into s.power, with 0 Hz in the centre
+func (s *sdrSource_t) averagePower() {
+	clear(s.power)
+	count := 0
+	for block := 0; block+2*kSdrFftSize <= len(s.iq); block += 2 * kSdrFftSize {
+		for i := range s.fft {
+			iq := s.iq[block+2*i:]
+			s.fft[i] = complex((float64(iq[0])-127.5)*s.window[i], (float64(iq[1])-127.5)*s.window[i])
+		}
+		fft(s.fft)
+		for i, x := range s.fft {
+			re, im := real(x), imag(x)
+			s.power[(i+kSdrFftSize/2)%kSdrFftSize] += re*re + im*im
+		}
+		count++
+	}
+	if count == 0 {
+		return // too few samples for an FFT
+	}
+	for i := range s.power {
+		s.power[i] /= float64(count)
+	}
+}
+
+// Maps s.power onto the kNumPoints bins in s.yp, with the noise floor at config_SdrFloor
+func (s *sdrSource_t) resample() {
+	const binMHz = kSpanMHz / kNumPoints
+	fftMHz := s.sampleRate / 1e6 / kSdrFftSize
+	db := s.db[:0]
+	for i := range s.yp {
+		f := kStartMHz + binMHz*float64(i) - s.centreMHz
+		if math.Abs(f) > s.sampleRate/2e6*kSdrUsable {
+			s.yp[i] = math.NaN()
+			continue
+		}
+		// Floor, as int() would round negative offsets towards 0
+		lo := max(int(math.Floor((f-binMHz/2)/fftMHz))+kSdrFftSize/2, 0)
+		hi := min(int(math.Floor((f+binMHz/2)/fftMHz))+kSdrFftSize/2, kSdrFftSize-1)
+		sum := 0.0
+		for k := lo; k <= hi; k++ {
+			sum += s.power[k]
+		}
+		s.yp[i] = 10 * math.Log10(sum/float64(hi-lo+1)+1e-12)
+		db = append(db, s.yp[i])
+	}
+	if len(db) == 0 {
+		clear(s.yp)
+		return
+	}
+	s.db = db
+	slices.Sort(db)
+	noiseDb := db[len(db)/10] // 10th percentile
+	for i, yp := range s.yp {
+		if math.IsNaN(yp) {
+			s.yp[i] = 0
+		} else {
+			s.yp[i] = config_SdrFloor + (yp-noiseDb)*kYpPerDb
+		}
+	}
+}
+
+// In place radix-2 FFT. len(x) must be a power of 2.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ { // bit reversal
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		w := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			wk := complex(1, 0)
+			for k := range size / 2 {
+				a, b := x[start+k], x[start+k+size/2]*wk
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				wk *= w
+			}
+		}
+	}
+}
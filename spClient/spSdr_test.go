@@ -0,0 +1,175 @@
+package spClient
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Returns a file of rtl_tcp samples with a tone offsetMHz from the centre, on noise
+func toneFile(t *testing.T, offsetMHz, amplitude float64) string {
+	t.Helper()
+	const samples = config_SdrSampleRate / 10
+	rng := rand.New(rand.NewPCG(1, 2))
+	iq := make([]byte, 0, 2*samples)
+	for n := range samples {
+		phase := 2 * math.Pi * offsetMHz * 1e6 * float64(n) / config_SdrSampleRate
+		i := 127.5 + amplitude*math.Cos(phase) + 4*rng.NormFloat64()
+		q := 127.5 + amplitude*math.Sin(phase) + 4*rng.NormFloat64()
+		iq = append(iq, byte(min(max(math.Round(i), 0), 255)), byte(min(max(math.Round(q), 0), 255)))
+	}
+	name := filepath.Join(t.TempDir(), "iq.bin")
+	if err := os.WriteFile(name, iq, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestSdrTone(t *testing.T) {
+	const centreMHz = 10495.0
+	// either side of the centre, where int() rounded the wrong way, and further out
+	for _, toneMHz := range []float64{10494.30, 10494.99, 10495.02, 10495.80} {
+		src, err := newSdrSource("file:"+toneFile(t, toneMHz-centreMHz, 1), config_SdrSampleRate, centreMHz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frame := make([]byte, kMaxFrameSize)
+		n, err := src.Read(frame)
+		src.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var d frameDecoder_t
+		yp := make([]float32, kNumPoints)
+		if err := d.decode(frame[:n], yp); err != nil {
+			t.Fatal(err)
+		}
+
+		peak := 0
+		for i := range yp {
+			if yp[i] > yp[peak] {
+				peak = i
+			}
+		}
+		got := 100 * float32(peak) / kNumPoints // as Xp
+		if want := MHzToX(toneMHz); math.Abs(float64(got-want)) > 50.0/kNumPoints {
+			t.Errorf("tone at %v MHz: peak at x %v, want %v", toneMHz, got, want)
+		}
+	}
+}
+
+// Power in one FFT bin just below the centre is only in the point that covers it
+func TestSdrBinMapping(t *testing.T) {
+	s := &sdrSource_t{
+		sampleRate: config_SdrSampleRate,
+		centreMHz:  kStartMHz + kSpanMHz*459/kNumPoints,
+		power:      make([]float64, kSdrFftSize),
+		yp:         make([]float64, kNumPoints),
+	}
+	for i := range s.power {
+		s.power[i] = 1
+	}
+	s.power[kSdrFftSize/2-4] = 1000 // -4.7 to -3.5 kHz, within point 459 which starts at -4.9 kHz
+	s.resample()
+	if s.yp[459] <= config_SdrFloor || s.yp[458] != config_SdrFloor || s.yp[460] != config_SdrFloor {
+		t.Errorf("points 458 to 460 are %v", s.yp[458:461])
+	}
+}
+
+// An rtl_tcp server that sends noise and records the commands it is sent
+func fakeRtlTcp(t *testing.T) (string, <-chan [5]byte) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	cmds := make(chan [5]byte, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("RTL0\x00\x00\x00\x05\x00\x00\x00\x1d"))
+		go func() {
+			iq := make([]byte, 32*1024)
+			for i := range iq {
+				iq[i] = byte(120 + i%16)
+			}
+			for {
+				if _, err := conn.Write(iq); err != nil {
+					return
+				}
+			}
+		}()
+		for {
+			var cmd [5]byte
+			if _, err := io.ReadFull(conn, cmd[:]); err != nil {
+				return
+			}
+			cmds <- cmd
+		}
+	}()
+	return listener.Addr().String(), cmds
+}
+
+func TestSdrLnbLo(t *testing.T) {
+	defer SetLnbLo(0)
+	SetLnbLo(9749.9)
+	address, cmds := fakeRtlTcp(t)
+	src, err := newSdrSource(address, config_SdrSampleRate, 10495)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	frequency := func() uint32 {
+		for {
+			select {
+			case cmd := <-cmds:
+				if cmd[0] == kRtlTcpSetFreq {
+					return binary.BigEndian.Uint32(cmd[1:])
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("not tuned")
+			}
+		}
+	}
+	if got := frequency(); got != 745100000 {
+		t.Errorf("tuned to %v Hz, want 745100000", got)
+	}
+
+	SetLnbLo(9750.2) // calibrated
+	if _, err := src.Read(make([]byte, kMaxFrameSize)); err != nil {
+		t.Fatal(err)
+	}
+	if got := frequency(); got != 744800000 {
+		t.Errorf("retuned to %v Hz, want 744800000", got)
+	}
+}
+
+func TestSdrTooFewSamples(t *testing.T) {
+	s := &sdrSource_t{
+		sampleRate: config_SdrSampleRate,
+		centreMHz:  config_SdrCentreMHz,
+		iq:         make([]byte, kSdrFftSize), // half an FFT
+		window:     make([]float64, kSdrFftSize),
+		fft:        make([]complex128, kSdrFftSize),
+		power:      make([]float64, kSdrFftSize),
+		yp:         make([]float64, kNumPoints),
+	}
+	s.averagePower()
+	s.resample()
+	for i, yp := range s.yp {
+		if math.IsNaN(yp) || math.IsInf(yp, 0) {
+			t.Fatalf("point %v is %v", i, yp)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package spClient
+
+import "math"
+
+/***********************************************************************
+*
+*	SIGNAL AT A POINT ON THE SPECTRUM
+*
+************************************************************************/
+
+const (
+	config_SignalThresholdDb = 1.0 // above the noise floor, for a point to be part of a signal
+	kSignalSearchPoints      = 5   // either side of a touch which misses the signal
+	kMaxCentreError          = 0.1 // MHz between a carrier's centre and its channel
+
+	CarrierFrames = 5 // consecutive frames with a carrier before it is believed
+)
+
+type (
+	Signal_t struct {
+		CentreMHz    float64
+		BandwidthMHz float64 // where the signal is above config_SignalThresholdDb
+		PeakDb       float32
+		CnDb         float32 // mean of the central half above the noise floor
+	}
+
+	// Counts the consecutive frames with a carrier centred on a channel
+	CarrierCount_t struct {
+		MHz     float64
+		present int
+	}
+)
+
+// Returns the occupied bandwidth of a carrier of symbolRateKS
+func OccupiedMHz(symbolRateKS float64) float64 {
+	return symbolRateKS * (1 + kRollOff) / 1000
+}
+
+// Looks for the carrier in a frame. Returns the carrier and true if it is
+// there, and counts the consecutive frames it has been seen.
+func (c *CarrierCount_t) Update(spData *SpData_t) (Signal_t, bool) {
+	signal, ok := spData.SignalAt(MHzToX(c.MHz))
+	if !ok || math.Abs(signal.CentreMHz-c.MHz) > kMaxCentreError {
+		c.present = 0
+		return Signal_t{}, false
+	}
+	c.present++
+	return signal, true
+}
+
+// Returns true once the carrier has been seen for CarrierFrames
+func (c *CarrierCount_t) Present() bool {
+	return c.present >= CarrierFrames
+}
+
+// Returns the signal around x, or false if there is no signal there
+func (spData *SpData_t) SignalAt(x float32) (Signal_t, bool) {
+	if len(spData.Yp) != kNumPoints {
+		return Signal_t{}, false
+	}
+	threshold := dbToYp(spData.NoiseFloorDb + config_SignalThresholdDb)
+	above := func(i int) bool {
+		return i > 0 && i < kNumPoints-1 && spData.Yp[i] >= threshold
+	}
+
+	// find the nearest point above the threshold
+	touched := int(x / 100 * kNumPoints)
+	start := -1
+	for d := 0; d <= kSignalSearchPoints && start < 0; d++ {
+		if above(touched - d) {
+			start = touched - d
+		} else if above(touched + d) {
+			start = touched + d
+		}
+	}
+	if start < 0 {
+		return Signal_t{}, false
+	}
+
+	lo, hi := start, start
+	for above(lo - 1) {
+		lo--
+	}
+	for above(hi + 1) {
+		hi++
+	}
+
+	peak := spData.Yp[lo]
+	for _, y := range spData.Yp[lo : hi+1] {
+		peak = max(peak, y)
+	}
+	quarter := (hi - lo) / 4
+	binMHz := kSpanMHz / kNumPoints
+	return Signal_t{
+		CentreMHz:    kStartMHz + binMHz*float64(lo+hi)/2,
+		BandwidthMHz: binMHz * float64(hi-lo+1),
+		PeakDb:       ypToDb(peak),
+		CnDb:         meanDb(spData.Yp, lo+quarter, hi-quarter) - spData.NoiseFloorDb,
+	}, true
+}
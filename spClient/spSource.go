@@ -0,0 +1,109 @@
+package spClient
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+/***********************************************************************
+*
+*	SPECTRUM SOURCES
+*
+************************************************************************/
+
+type (
+	// A SpectrumSource delivers one raw spectrum frame, normally 1844 bytes, per Read
+	SpectrumSource interface {
+		Read(frame []byte) (int, error)
+		Close() error
+	}
+
+	// A source that can be connected again after a Read fails
+	reconnector interface {
+		reconnect() error
+	}
+
+	// The BATC wideband spectrum server
+	batcSource_t struct {
+		ws *websocket.Conn
+	}
+
+	SpConfig_t struct {
+		Source     string      // "batc", "replay", "synth" or "sdr"
+		File       string      // capture or frames for "replay"
+		Speed      float64     // replay speed, where 1 or 0 is the original pace
+		Record     string      // if set, every frame read is also captured to this file
+		Carriers   []Carrier_t // carriers for "synth", or config_SynthCarriers if nil
+		Address    string      // rtl_tcp host:port or file:name for "sdr"
+		SampleRate float64     // Hz for "sdr", or config_SdrSampleRate if 0
+		CentreMHz  float64     // for "sdr", or config_SdrCentreMHz if 0
+	}
+)
+
+// Returns the SpectrumSource selected by cfg.Source, recording to cfg.Record if set
+func NewSpectrumSource(cfg SpConfig_t) (SpectrumSource, error) {
+	src, err := newSource(cfg)
+	if err != nil || cfg.Record == "" {
+		return src, err
+	}
+	return newRecorder(src, cfg.Record)
+}
+
+func newSource(cfg SpConfig_t) (SpectrumSource, error) {
+	switch cfg.Source {
+	case "", "batc":
+		return dialBatc()
+	case "replay":
+		return newReplaySource(cfg.File, cfg.Speed)
+	case "synth":
+		carriers := cfg.Carriers
+		if carriers == nil {
+			carriers = config_SynthCarriers
+		}
+		return newSyntheticSource(carriers), nil
+	case "sdr":
+		return newSdrSource(cfg.Address, cfg.SampleRate, cfg.CentreMHz)
+	}
+	return nil, fmt.Errorf("unknown spectrum source %q", cfg.Source)
+}
+
+var errCannotReconnect = errors.New("source cannot reconnect")
+
+// Returns a source connected to the BATC wideband spectrum server
+func dialBatc() (SpectrumSource, error) {
+	// TODO: needs a timeout. see https://pkg.go.dev/nhooyr.io/websocket
+	//	which uses: ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	const MAXTRIES = 10
+	for i := 1; i <= MAXTRIES; i++ {
+		log.Printf("INFO Dial attempt %v", i)
+		ws, err := websocket.Dial(config_Url, "", config_Origin)
+		if err == nil {
+			return &batcSource_t{ws: ws}, nil
+		}
+		time.Sleep(time.Millisecond * 500)
+	}
+	return nil, fmt.Errorf("dial aborted after %v attemps", MAXTRIES)
+}
+
+func (b *batcSource_t) Read(frame []byte) (int, error) {
+	return b.ws.Read(frame)
+}
+
+func (b *batcSource_t) Close() error {
+	return b.ws.Close()
+}
+
+// Closes the websocket and dials the server again, once
+func (b *batcSource_t) reconnect() error {
+	b.ws.Close()
+	ws, err := websocket.Dial(config_Url, "", config_Origin)
+	if err != nil {
+		return err
+	}
+	b.ws = ws
+	return nil
+}
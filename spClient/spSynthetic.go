@@ -0,0 +1,94 @@
+package spClient
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+/***********************************************************************
+*
+*	SYNTHETIC SPECTRUM
+*
+*	Generates frames with a noise floor and carriers, as if received
+*	from the BATC server, for testing on the bench.
+*
+************************************************************************/
+
+const (
+	config_SynthInterval   = 100 * time.Millisecond
+	config_SynthNoiseFloor = 10.0 // 0 to 100 scale
+	config_SynthNoiseDb    = 0.5  // peak to peak noise
+
+	kRollOff = 0.35
+)
+
+type (
+	Carrier_t struct {
+		FrequencyMHz float64
+		SymbolRateKS float64
+		LevelDb      float64 // above the noise floor
+	}
+
+	syntheticSource_t struct {
+		carriers []Carrier_t
+		ticker   *time.Ticker
+		yp       []float64
+		noisy    []float64
+	}
+)
+
+var (
+	config_SynthCarriers = []Carrier_t{
+		{10491.50, 1500, 8}, // beacon
+		{10494.75, 1000, 4},
+		{10497.25, 333, 5},
+		{10498.50, 125, 3},
+	}
+)
+
+func newSyntheticSource(carriers []Carrier_t) *syntheticSource_t {
+	s := &syntheticSource_t{
+		carriers: carriers,
+		ticker:   time.NewTicker(config_SynthInterval),
+		yp:       make([]float64, kNumPoints),
+		noisy:    make([]float64, kNumPoints),
+	}
+	for i := range s.yp {
+		f := kStartMHz + kSpanMHz*float64(i)/kNumPoints
+		power := 1.0 // noise
+		for _, c := range carriers {
+			power += math.Pow(10, c.LevelDb/10) * carrierShape(f, c)
+		}
+		s.yp[i] = config_SynthNoiseFloor + 10*math.Log10(power)*kYpPerDb
+	}
+	return s
+}
+
+// Returns 1 within the flat top of a carrier, falling to 0 across the roll-off
+func carrierShape(f float64, c Carrier_t) float64 {
+	halfWidth := c.SymbolRateKS / 2000 // MHz
+	x := math.Abs(f-c.FrequencyMHz) / halfWidth
+	switch {
+	case x <= 1-kRollOff:
+		return 1
+	case x >= 1+kRollOff:
+		return 0
+	}
+	return 0.5 * (1 + math.Cos(math.Pi*(x-1+kRollOff)/(2*kRollOff)))
+}
+
+// Waits for the next frame interval and encodes a new frame with fresh noise
+func (s *syntheticSource_t) Read(frame []byte) (int, error) {
+	<-s.ticker.C
+	for i, yp := range s.yp {
+		s.noisy[i] = yp + (rand.Float64()-0.5)*config_SynthNoiseDb*kYpPerDb
+	}
+	encodeFrame(s.noisy, frame)
+	return kFrameSize, nil
+}
+
+func (s *syntheticSource_t) Close() error {
+	s.ticker.Stop()
+	return nil
+}
@@ -0,0 +1,88 @@
+package spClient
+
+/***********************************************************************
+*
+*	AVERAGE, PEAK HOLD AND MAX HOLD TRACES
+*
+************************************************************************/
+
+const (
+	config_AverageWeight = 0.2 // of each new frame in the exponential average
+	config_PeakDecay     = 0.2 // per frame, on the 0 to 100 scale
+
+	CmdToggleAverage  = 1
+	CmdTogglePeakHold = 2
+	CmdToggleMaxHold  = 3
+)
+
+type (
+	SpCmd_t int
+
+	traces_t struct {
+		average  []float32
+		peakHold []float32
+		maxHold  []float32
+	}
+)
+
+// Turns a trace on or off. A trace is nil while off and restarts from the
+// next frame when turned on.
+func (t *traces_t) toggle(cmd SpCmd_t) {
+	toggle := func(trace *[]float32) {
+		if *trace == nil {
+			*trace = make([]float32, 0, kNumPoints)
+		} else {
+			*trace = nil
+		}
+	}
+	switch cmd {
+	case CmdToggleAverage:
+		toggle(&t.average)
+	case CmdTogglePeakHold:
+		toggle(&t.peakHold)
+	case CmdToggleMaxHold:
+		toggle(&t.maxHold)
+	}
+}
+
+// Adds the latest frame to each trace which is on
+func (t *traces_t) update(yp []float32) {
+	if t.average != nil {
+		if len(t.average) == 0 {
+			t.average = append(t.average, yp...)
+		}
+		for i, y := range yp {
+			t.average[i] += config_AverageWeight * (y - t.average[i])
+		}
+	}
+	if t.peakHold != nil {
+		if len(t.peakHold) == 0 {
+			t.peakHold = append(t.peakHold, yp...)
+		}
+		for i, y := range yp {
+			t.peakHold[i] = max(y, t.peakHold[i]-config_PeakDecay)
+		}
+	}
+	if t.maxHold != nil {
+		if len(t.maxHold) == 0 {
+			t.maxHold = append(t.maxHold, yp...)
+		}
+		for i, y := range yp {
+			t.maxHold[i] = max(y, t.maxHold[i])
+		}
+	}
+}
+
+// Copies the traces to spData, leaving nil those which are off
+func (t *traces_t) copyTo(spData *SpData_t) {
+	spData.Average = clone(t.average)
+	spData.PeakHold = clone(t.peakHold)
+	spData.MaxHold = clone(t.maxHold)
+}
+
+func clone(trace []float32) []float32 {
+	if trace == nil {
+		return nil
+	}
+	return append(make([]float32, 0, len(trace)), trace...)
+}
@@ -0,0 +1,99 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"q100receiver/rxControl"
+	"q100receiver/spClient"
+	"strconv"
+
+	"github.com/ajstarks/giocanvas"
+)
+
+const (
+	kMaxZoom      = 16
+	kPanFraction  = 0.25 // of the visible span, for each pan button press
+	kMinLabelStep = 0.25 // MHz
+)
+
+// The part of the spectrum shown, with x coordinates from 0.0 to 100.0
+//
+//	The view is centred on the tuning marker, offset by pan.
+type spectrumView_t struct {
+	zoom int // 1, 2, 4, 8 or 16
+	pan  float32
+}
+
+var channels = rxControl.Channels()
+
+func (v *spectrumView_t) zoomIn() {
+	if v.zoom < kMaxZoom {
+		v.zoom *= 2
+	}
+	v.pan = 0
+}
+
+func (v *spectrumView_t) zoomOut() {
+	if v.zoom > 1 {
+		v.zoom /= 2
+	}
+	v.pan = 0
+}
+
+// Moves the view by kPanFraction of its span, ie. direction -1 or 1
+func (v *spectrumView_t) panBy(direction float32) {
+	v.pan += direction * kPanFraction * v.span()
+}
+
+func (v *spectrumView_t) span() float32 {
+	return 100 / float32(v.zoom)
+}
+
+// Returns the x coordinate at the left of the view, which never goes beyond either end
+func (v *spectrumView_t) left(markerCentre float32) float32 {
+	left := markerCentre + v.pan - v.span()/2
+	return min(max(left, 0), 100-v.span())
+}
+
+// Converts x to a canvas coordinate, where the view is 0.0 to 100.0
+func (v *spectrumView_t) toCanvas(x, left float32) float32 {
+	return (x - left) * float32(v.zoom)
+}
+
+// Converts a canvas coordinate back to x
+func (v *spectrumView_t) fromCanvas(cx, left float32) float32 {
+	return left + cx/float32(v.zoom)
+}
+
+// Draws vertical gridlines with MHz labels along the bottom, and channel
+// numbers along the top, using the same frequency mapping as the markers
+func (v *spectrumView_t) drawGraticule(canvas *giocanvas.Canvas, left float32) {
+	lo, hi := spClient.XToMHz(left), spClient.XToMHz(left+v.span())
+
+	for _, ch := range channels {
+		if ch.MHz < lo || ch.MHz > hi {
+			continue
+		}
+		x := v.toCanvas(spClient.MHzToX(ch.MHz), left)
+		canvas.VLine(x, 5, 88, 0.005, q100color.gfxGraticule)
+		// at full span, only the beacon and odd channels have room for a label
+		if n, _ := strconv.Atoi(ch.Number); v.zoom == 1 && n != 0 && n%2 == 0 {
+			continue
+		}
+		canvas.CText(x, 96, 1.5, "/ "+ch.Number, q100color.gfxAxisLabel)
+	}
+
+	step := max(1/float64(v.zoom), kMinLabelStep)
+	for f := math.Ceil(lo/step) * step; f <= hi; f += step {
+		x := v.toCanvas(spClient.MHzToX(f), left)
+		if x > 4 && x < 96 {
+			canvas.VLine(x, 5, 88, 0.01, q100color.gfxGraticule)
+			canvas.CText(x, 1, 1.5, fmt.Sprintf("%.2f", f), q100color.gfxAxisLabel)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"gioui.org/layout"
+	"github.com/ajstarks/giocanvas"
+)
+
+const (
+	kPanelTextSize = 2.2 // percent of the canvas width
+	kPanelRowStep  = 9   // percent of the canvas height
+	kPanelMaxRows  = 9
+)
+
+// Returns a table of every elementary stream in the service
+//
+//	PIDs come from the longmynd status, with their bitrates from the TS.
+func (ui *UI) q100_StreamsPanel(gtx C, width, height int) D {
+	canvas := giocanvas.Canvas{
+		Width:   float32(width),
+		Height:  float32(height),
+		Context: gtx,
+		Theme:   ui.th,
+	}
+	canvas.Background(q100color.gfxBgd)
+
+	columns := [...]float32{5, 18, 32, 48, 70}
+	row := func(y float32, c [5]string) {
+		for i, s := range c {
+			canvas.Text(columns[i], y, kPanelTextSize, s, q100color.labelOrange)
+		}
+	}
+	header := [5]string{"PID", "Type", "Kind", "Codec", "kb/s"}
+	for i, s := range header {
+		canvas.Text(columns[i], 90, kPanelTextSize, s, q100color.labelWhite)
+	}
+
+	if len(lmData.Streams) == 0 {
+		canvas.Text(columns[0], 90-kPanelRowStep, kPanelTextSize, "No streams", q100color.labelOrange)
+	}
+	kbps := make(map[int]float64, len(lmData.Bitrates))
+	for _, b := range lmData.Bitrates {
+		kbps[int(b.Pid)] = b.Kbps
+	}
+	for i, es := range lmData.Streams {
+		if i == kPanelMaxRows {
+			break
+		}
+		rate := "-"
+		if k, ok := kbps[es.Pid]; ok {
+			rate = fmt.Sprintf("%.0f", k)
+		}
+		row(90-float32(i+1)*kPanelRowStep, [5]string{
+			fmt.Sprint(es.Pid), fmt.Sprint(es.Type), es.Kind, es.Codec, rate,
+		})
+	}
+
+	return layout.Dimensions{
+		Size: image.Point{X: width, Y: height},
+	}
+}
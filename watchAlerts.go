@@ -0,0 +1,64 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"q100receiver/rxWatch"
+	"time"
+)
+
+const (
+	config_AlertTime = 30 * time.Second // an alert is shown in the status row
+)
+
+// a short tone, using ffplay which is already needed for the video
+var config_ChimeCommand = []string{"ffplay", "-nodisp", "-autoexit", "-loglevel", "quiet",
+	"-f", "lavfi", "-i", "sine=frequency=880:duration=0.4"}
+
+// Shows, sounds and publishes alerts from watch mode
+type watchAlerts_t struct {
+	watcher  *rxWatch.Watcher
+	watching bool
+	message  string
+	until    time.Time
+}
+
+func (wa *watchAlerts_t) toggle() {
+	wa.watching = !wa.watching
+	if wa.watching {
+		wa.watcher.Start(time.Now())
+		log.Printf("INFO watching for new carriers")
+	}
+}
+
+// Checks the latest spectrum for new carriers
+func (wa *watchAlerts_t) update(now time.Time) {
+	if !wa.watching {
+		return
+	}
+	for _, alert := range wa.watcher.Update(&spData, now) {
+		wa.message = fmt.Sprintf("NEW CARRIER / %s  %.2f MHz  C/N %.1f dB",
+			alert.Channel.Number, alert.Signal.CentreMHz, alert.Signal.CnDb)
+		wa.until = now.Add(config_AlertTime)
+		log.Printf("INFO %v", wa.message)
+		localApi.publish("alert", wa.message)
+		go chime()
+	}
+}
+
+// Returns the message to show, if any
+func (wa *watchAlerts_t) showing(now time.Time) (string, bool) {
+	return wa.message, now.Before(wa.until)
+}
+
+func chime() {
+	if err := exec.Command(config_ChimeCommand[0], config_ChimeCommand[1:]...).Run(); err != nil {
+		log.Printf("WARN chime failed: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ *  Q-100 Receiver
+ *  Copyright (c) 2023 Michael Naylor EA7KIR (https://michaelnaylor.es)
+ */
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"log"
+
+	"gioui.org/layout"
+	"gioui.org/op/paint"
+	"gioui.org/widget"
+)
+
+const (
+	config_WaterfallDepth   = 100       // rows of history
+	config_WaterfallPalette = "thermal" // see waterfallPalettes
+	config_WaterfallMinYp   = 10        // darkest, on the 0 to 100 spectrum scale
+	config_WaterfallMaxYp   = 60        // brightest
+)
+
+// colour gradients, from weakest to strongest
+var waterfallPalettes = map[string][]color.RGBA{
+	"thermal": {
+		{R: 0, G: 0, B: 0, A: 255},
+		{R: 0, G: 0, B: 140, A: 255},
+		{R: 0, G: 160, B: 200, A: 255},
+		{R: 240, G: 220, B: 0, A: 255},
+		{R: 255, G: 40, B: 0, A: 255},
+		{R: 255, G: 255, B: 255, A: 255},
+	},
+	"green": {
+		{R: 0, G: 0, B: 0, A: 255},
+		{R: 0, G: 100, B: 0, A: 255},
+		{R: 0, G: 200, B: 0, A: 255},
+		{R: 220, G: 255, B: 220, A: 255},
+	},
+	"grey": {
+		{R: 0, G: 0, B: 0, A: 255},
+		{R: 255, G: 255, B: 255, A: 255},
+	},
+}
+
+// A scrolling waterfall of spectrum frames, newest at the top
+//
+//	The history is a ring buffer of rows, updated one row per frame and drawn
+//	as two parts split at the newest row, so nothing is copied or allocated.
+type waterfall_t struct {
+	history *image.RGBA // ring buffer of rows, next holds the newest
+	next    int
+	colors  [256]color.RGBA
+}
+
+func newWaterfall(width, depth int, paletteName string) *waterfall_t {
+	palette, ok := waterfallPalettes[paletteName]
+	if !ok {
+		log.Printf("WARN unknown waterfall palette %v", paletteName)
+		palette = waterfallPalettes["grey"]
+	}
+	w := &waterfall_t{
+		history: image.NewRGBA(image.Rect(0, 0, width, depth)),
+	}
+	for i := range w.colors {
+		w.colors[i] = gradient(palette, float32(i)/float32(len(w.colors)-1))
+	}
+	return w
+}
+
+// Returns the colour at position f, from 0 to 1, along the palette
+func gradient(palette []color.RGBA, f float32) color.RGBA {
+	pos := f * float32(len(palette)-1)
+	i := min(int(pos), len(palette)-2)
+	t := pos - float32(i)
+	a, b := palette[i], palette[i+1]
+	mix := func(x, y uint8) uint8 {
+		return uint8(float32(x) + t*(float32(y)-float32(x)))
+	}
+	return color.RGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: 255}
+}
+
+// Adds a spectrum frame as the newest row
+func (w *waterfall_t) addRow(yp []float32) {
+	bounds := w.history.Bounds()
+	w.next = (w.next + bounds.Dy() - 1) % bounds.Dy()
+	row := w.history.Pix[w.next*w.history.Stride:]
+	for x := range bounds.Dx() {
+		v := (yp[x*len(yp)/bounds.Dx()] - config_WaterfallMinYp) / (config_WaterfallMaxYp - config_WaterfallMinYp)
+		c := w.colors[int(min(max(v, 0), 1)*float32(len(w.colors)-1))]
+		row[4*x], row[4*x+1], row[4*x+2], row[4*x+3] = c.R, c.G, c.B, c.A
+	}
+}
+
+// Returns the part of the waterfall from x coordinates left to left+span,
+// stretched to size pixels
+//
+//	Rows are only added between frames, so each new ImageOp sees a steady image.
+func (w *waterfall_t) layout(gtx C, size image.Point, left, span float32) D {
+	bounds := w.history.Bounds()
+	width, depth := float32(bounds.Dx()), bounds.Dy()
+	x0 := int(left / 100 * width)
+	x1 := max(int((left+span)/100*width), x0+1)
+
+	// rows next to the end are the newest, then the oldest wrap from the start
+	newestHeight := size.Y * (depth - w.next) / depth
+	part := func(y0, y1, height int) layout.FlexChild {
+		return layout.Rigid(func(gtx C) D {
+			if y0 == y1 || height == 0 {
+				return D{}
+			}
+			rows := w.history.SubImage(image.Rect(x0, y0, x1, y1))
+			gtx.Constraints = layout.Exact(image.Pt(size.X, height))
+			return widget.Image{Src: paint.NewImageOp(rows), Fit: widget.Fill}.Layout(gtx)
+		})
+	}
+	gtx.Constraints = layout.Exact(size)
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		part(w.next, depth, newestHeight),
+		part(0, w.next, size.Y-newestHeight),
+	)
+}